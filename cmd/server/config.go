@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+)
+
+// runConfig inspects the effective configuration without connecting to
+// anything:
+//
+//	server [--config file] [--key=value ...] config print
+//
+// print writes every setting with secrets masked, then reports validation
+// errors and exits non-zero if there are any.
+func runConfig(args []string, cfg config.Config) {
+	if len(args) != 1 || args[0] != "print" {
+		log.Fatalf("usage: server config print")
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		log.Fatalf("Failed to print configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+}
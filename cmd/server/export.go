@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// runExport streams posts to a file, or stdout with "-":
+//
+//	server export -format markdown -out posts.tar.gz [-tag a,b] [-tag-mode all] [-from RFC3339] [-to RFC3339]
+//
+// Errors are returned rather than fatal so the output is flushed and
+// closed first.
+func runExport(args []string, logger *zap.Logger, db *gorm.DB) (err error) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", service.ExportNDJSON, "ndjson, csv or markdown (tar.gz)")
+	out := fs.String("out", "-", "output file, - for stdout")
+	tags := fs.String("tag", "", "comma separated tags to filter on")
+	tagMode := fs.String("tag-mode", repository.TagModeAny, "any or all")
+	from := fs.String("from", "", "only posts created at or after this RFC3339 time")
+	to := fs.String("to", "", "only posts created before this RFC3339 time")
+	_ = fs.Parse(args)
+
+	f := repository.PostFilter{TagMode: *tagMode}
+	for _, t := range strings.Split(*tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Tags = append(f.Tags, t)
+		}
+	}
+	for _, b := range []struct {
+		raw string
+		dst **time.Time
+	}{{*from, &f.CreatedFrom}, {*to, &f.CreatedTo}} {
+		if b.raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, b.raw)
+		if err != nil {
+			return fmt.Errorf("invalid time %q: %w", b.raw, err)
+		}
+		*b.dst = &t
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = file
+	}
+	bw := bufio.NewWriter(w)
+
+	ex := service.NewExporter(logger, db, repository.NewPostRepository(), auth.DefaultPolicy())
+	n, err := ex.Export(auth.WithPrincipal(context.Background(), auth.System), bw, *format, f)
+	// flush what was written even on failure, so the output ends where the
+	// export stopped
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return fmt.Errorf("after %d posts: %w", n, err)
+	}
+	log.Printf("Exported %d posts", n)
+	return nil
+}
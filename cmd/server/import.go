@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/cache"
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/database"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/search"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// runImport creates posts from an NDJSON or CSV file, or stdin with "-":
+//
+//	server import -file posts.csv [-format csv] [-batch 100] [-dry-run]
+//
+// The report goes to stdout, also when reading the input fails partway.
+// Imported posts have no author.
+func runImport(args []string, cfg config.Config, logger *zap.Logger, db *gorm.DB, replicas *database.Replicas, cc cache.Cache, es *search.ESClient) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "-", "input file, - for stdin")
+	format := fs.String("format", "", "ndjson or csv (default: from the file extension)")
+	batch := fs.Int("batch", 100, "posts per transaction")
+	dryRun := fs.Bool("dry-run", false, "validate only, write nothing")
+	_ = fs.Parse(args)
+
+	if *format == "" {
+		*format = service.ImportFormat(*file)
+	}
+	if *format == "" {
+		log.Fatalf("Cannot tell the format of %q, pass -format ndjson or -format csv", *file)
+	}
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Open %s: %v", *file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	posts, revisions, outbox := repository.NewPostRepository(), repository.NewRevisionRepository(), repository.NewOutboxRepository()
+	// only used to invalidate the server's cached posts; the CLI never searches
+	svc := service.NewPostService(cfg, logger, db, replicas, cc, posts, outbox, revisions, nil, auth.DefaultPolicy(), nil)
+	im := service.NewImporter(cfg, logger, db, posts, revisions, outbox, es, auth.DefaultPolicy(), svc)
+	rep, err := im.Run(auth.WithPrincipal(context.Background(), auth.System), in, service.ImportOptions{Format: *format, BatchSize: *batch, DryRun: *dryRun})
+	if rep == nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(rep)
+	if err != nil {
+		log.Fatalf("Import aborted after row %d: %v", rep.Total, err)
+	}
+}
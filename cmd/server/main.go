@@ -1,16 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/xuanviet96/seta-training/internal/auth"
 	"github.com/xuanviet96/seta-training/internal/cache"
 	"github.com/xuanviet96/seta-training/internal/config"
 	"github.com/xuanviet96/seta-training/internal/database"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
 	httpserver "github.com/xuanviet96/seta-training/internal/http"
+	"github.com/xuanviet96/seta-training/internal/lifecycle"
 	"github.com/xuanviet96/seta-training/internal/logger"
+	"github.com/xuanviet96/seta-training/internal/metrics"
 	"github.com/xuanviet96/seta-training/internal/search"
+	"github.com/xuanviet96/seta-training/internal/tracing"
 
 	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -19,25 +34,64 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	// Load configuration
-	cfg := config.Load()
+	// Load configuration: defaults < config file < environment < flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if len(args) > 0 && args[0] == "config" {
+		runConfig(args[1:], cfg)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
 	// Initialize logger
-	logger := logger.New(cfg.AppEnv)
+	logger, logLevel, err := logger.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer func() { _ = logger.Sync() }()
 
 	// Initialize database
-	db, err := database.Connect(cfg.DatabaseURL, logger)
+	db, err := database.Connect(cfg.DB, logger)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	replicas, err := database.ConnectReplicas(cfg.DB, logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize read replicas: %v", err)
+	}
 
-	// Initialize Redis cache
-	redis, err := cache.New(cfg, logger)
+	// Gorm callbacks must be registered before anything queries the
+	// handles; registering them later races with running queries.
+	flushTraces, err := tracing.Setup(cfg)
 	if err != nil {
-		log.Printf("Failed to initialize Redis: %v", err)
-		// Continue without Redis if it fails
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	for _, gdb := range append([]*gorm.DB{db}, replicas.All()...) {
+		if err := metrics.InstrumentGorm(gdb); err != nil {
+			log.Fatalf("Failed to instrument database: %v", err)
+		}
+		if err := tracing.InstrumentGorm(gdb); err != nil {
+			log.Fatalf("Failed to instrument database: %v", err)
+		}
+	}
+
+	// Migrations run before anything else touches the schema
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(args[1:], logger, db)
+		_ = flushTraces(context.Background())
+		return
+	}
+	if err := checkSchema(cfg, logger, db); err != nil {
+		log.Fatalf("Database schema check failed: %v", err)
 	}
 
+	// Initialize cache (Redis, in-process LRU or disabled)
+	cc := cache.Open(cfg, logger)
+
 	// Initialize Elasticsearch
 	es, err := search.New(cfg, logger)
 	if err != nil {
@@ -45,18 +99,123 @@ func main() {
 		// Continue without ES if it fails
 	}
 
-	// Ensure ES index exists
+	// Ensure ES index exists; the server retries in the background below
+	// if ES is not up yet
 	if es != nil {
-		if err := search.EnsureIndex(nil, es, cfg.ESIndex, logger); err != nil {
+		if err := search.EnsureIndex(context.Background(), es, cfg.ES.Index, logger); err != nil {
 			log.Printf("Failed to ensure ES index: %v", err)
 		}
 	}
 
+	// Subcommands share the setup above and exit instead of serving
+	if len(args) > 0 {
+		switch args[0] {
+		case "reindex":
+			runReindex(args[1:], cfg, logger, db, es)
+		case "import":
+			runImport(args[1:], cfg, logger, db, replicas, cc, es)
+		case "export":
+			if err := runExport(args[1:], logger, db); err != nil {
+				_ = flushTraces(context.Background())
+				log.Fatalf("Export failed: %v", err)
+			}
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		_ = flushTraces(context.Background())
+		return
+	}
+
+	// Background work is tracked so shutdown can wait for it
+	lc := lifecycle.New()
+
+	// Deliver outbox events to ES. Until ES is reachable and its index is
+	// in place they stay pending.
+	outbox := service.NewOutboxDispatcher(cfg, logger, db, repository.NewOutboxRepository(), repository.NewPostRepository(), es)
+	if es != nil {
+		lc.Go(func(ctx context.Context) {
+			if !es.Ready() && search.WaitIndex(ctx, es, cfg.ES.Index, logger) != nil {
+				return
+			}
+			outbox.Run(auth.WithPrincipal(ctx, auth.System))
+		})
+	}
+
+	tokens, err := auth.NewTokenManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize auth: %v", err)
+	}
+	policy, err := auth.LoadPolicy(cfg.Auth.RBACPolicyFile)
+	if err != nil {
+		log.Fatalf("Failed to load RBAC policy: %v", err)
+	}
+
 	// Initialize HTTP router
-	router := httpserver.NewRouter(cfg, logger, db, redis, es)
+	router := httpserver.NewRouter(cfg, logger, db, replicas, cc, es, outbox, tokens, policy, lc, logLevel)
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.HTTP.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Server starting on port %s", cfg.AppPort)
-	if err := router.Run(":" + cfg.AppPort); err != nil {
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", cfg.HTTP.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	// a second signal kills the process immediately
+	stop()
+
+	shutdown(cfg, logger, srv, lc, flushTraces, db, replicas, cc, es)
+}
+
+// shutdown fails readiness, drains in-flight requests and background work
+// within cfg.Shutdown.Timeout, then closes the backing clients.
+func shutdown(cfg config.Config, logger *zap.Logger, srv *http.Server, lc *lifecycle.Lifecycle, flushTraces func(context.Context) error, db *gorm.DB, replicas *database.Replicas, cc cache.Cache, es *search.ESClient) {
+	logger.Info("shutting down", zap.Duration("timeout", cfg.Shutdown.Timeout))
+	lc.BeginShutdown()
+
+	// give load balancers time to notice the failing health check
+	if cfg.Shutdown.DrainDelay > 0 {
+		time.Sleep(cfg.Shutdown.DrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Warn("http server did not drain", zap.Error(err))
+	}
+	if err := lc.Wait(ctx); err != nil {
+		logger.Warn("background work did not finish", zap.Error(err))
+	}
+	if err := flushTraces(ctx); err != nil {
+		logger.Warn("flush traces", zap.Error(err))
+	}
+
+	if err := cc.Close(); err != nil {
+		logger.Warn("close cache", zap.Error(err))
+	}
+	if es != nil {
+		es.Close()
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logger.Warn("close database", zap.Error(err))
+		}
+	}
+	if err := replicas.Close(); err != nil {
+		logger.Warn("close read replicas", zap.Error(err))
 	}
+	logger.Info("shutdown complete")
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/database"
+	"github.com/xuanviet96/seta-training/migrations"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// runMigrate manages the schema with the migrations embedded in the binary:
+//
+//	server migrate up [-steps N]
+//	server migrate down [-steps N]
+//	server migrate status
+//	server migrate redo
+func runMigrate(args []string, logger *zap.Logger, db *gorm.DB) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: migrate up|down|status|redo")
+	}
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := fs.Int("steps", 0, "number of migrations (up: 0 = all pending, down: 0 = 1)")
+	_ = fs.Parse(args[1:])
+
+	m, err := database.NewMigrator(db, migrations.FS, logger)
+	if err != nil {
+		log.Fatalf("Load migrations: %v", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx, *steps)
+		printMigrations("Applied", done)
+		if err != nil {
+			log.Fatalf("Migrate up failed: %v", err)
+		}
+	case "down":
+		done, err := m.Down(ctx, *steps)
+		printMigrations("Reverted", done)
+		if err != nil {
+			log.Fatalf("Migrate down failed: %v", err)
+		}
+	case "redo":
+		mig, err := m.Redo(ctx)
+		if err != nil {
+			log.Fatalf("Migrate redo failed: %v", err)
+		}
+		if mig != nil {
+			fmt.Printf("Redid %04d_%s\n", mig.Version, mig.Name)
+		}
+	case "status":
+		st, err := m.Status(ctx)
+		if err != nil {
+			log.Fatalf("Migrate status failed: %v", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range st {
+			at := "pending"
+			if s.AppliedAt != nil {
+				at = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, at)
+		}
+		_ = tw.Flush()
+	default:
+		log.Fatalf("Unknown migrate command %q, want up|down|status|redo", args[0])
+	}
+}
+
+func printMigrations(verb string, done []database.Migration) {
+	if len(done) == 0 {
+		fmt.Println("Nothing to do")
+	}
+	for _, mig := range done {
+		fmt.Printf("%s %04d_%s\n", verb, mig.Version, mig.Name)
+	}
+}
+
+// checkSchema applies pending migrations when DB_AUTO_MIGRATE is set and
+// otherwise refuses to start on a schema the code is ahead of.
+func checkSchema(cfg config.Config, logger *zap.Logger, db *gorm.DB) error {
+	m, err := database.NewMigrator(db, migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if cfg.DB.AutoMigrate {
+		if _, err := m.Up(ctx, 0); err != nil {
+			return err
+		}
+		return nil
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations pending (next %04d_%s); run `server migrate up` or set DB_AUTO_MIGRATE=true",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
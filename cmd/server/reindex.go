@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/search"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// runReindex rebuilds the ES index behind cfg.ES.Index from Postgres:
+//
+//	server reindex [-batch 500] [-delete-old]
+func runReindex(args []string, cfg config.Config, logger *zap.Logger, db *gorm.DB, es *search.ESClient) {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	batch := fs.Int("batch", 500, "posts per bulk request")
+	deleteOld := fs.Bool("delete-old", false, "delete the previous index after the alias swap")
+	_ = fs.Parse(args)
+
+	if es == nil {
+		log.Fatalf("Reindex needs Elasticsearch; set es.addr (ES_ADDR)")
+	}
+
+	r := service.NewReindexer(cfg, logger, db, repository.NewPostRepository(), repository.NewOutboxRepository(), es)
+	rep, err := r.Run(auth.WithPrincipal(context.Background(), auth.System), service.ReindexOptions{BatchSize: *batch, DeleteOld: *deleteOld})
+	if err != nil {
+		log.Fatalf("Reindex failed: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(rep)
+}
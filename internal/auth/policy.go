@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Permissions are "resource:action[:scope]". A scope of own applies to
+// resources the caller authored, any to all of them.
+const (
+	PermPostRead       = "post:read"
+	PermPostCreate     = "post:create"
+	PermPostUpdateOwn  = "post:update:own"
+	PermPostUpdateAny  = "post:update:any"
+	PermPostDeleteOwn  = "post:delete:own"
+	PermPostDeleteAny  = "post:delete:any"
+	PermPostRestoreOwn = "post:restore:own"
+	PermPostRestoreAny = "post:restore:any"
+	PermPostPurge      = "post:purge"
+	PermPostImport     = "post:import"
+	PermPostExport     = "post:export"
+	PermOutboxManage   = "outbox:manage"
+	PermSearchReindex  = "search:reindex"
+	PermLogManage      = "log:manage"
+)
+
+// Policy decides whether a role holds a permission. RolePolicy is the
+// built-in implementation; anything else (an external engine, a database
+// table) can be plugged in behind the same interface.
+type Policy interface {
+	Can(role, perm string) bool
+}
+
+// RolePolicy maps roles to granted permissions. A grant of "*" matches
+// everything and "post:*" matches every post permission.
+type RolePolicy struct {
+	roles map[string][]string
+}
+
+func NewRolePolicy(roles map[string][]string) *RolePolicy {
+	return &RolePolicy{roles: roles}
+}
+
+// DefaultPolicy is used when no policy file is configured.
+func DefaultPolicy() *RolePolicy {
+	return NewRolePolicy(map[string][]string{
+		RoleAdmin: {"*"},
+		RoleEditor: {
+			PermPostRead, PermPostCreate,
+			PermPostUpdateAny, PermPostDeleteAny, PermPostRestoreAny,
+			PermPostImport, PermPostExport,
+		},
+		RoleAuthor: {
+			PermPostRead, PermPostCreate,
+			PermPostUpdateOwn, PermPostDeleteOwn, PermPostRestoreOwn,
+		},
+		RoleReader: {PermPostRead},
+	})
+}
+
+// LoadPolicy reads a YAML, JSON or TOML file of the form
+//
+//	roles:
+//	  editor: [post:read, post:create, post:update:any]
+//
+// An empty path yields DefaultPolicy.
+func LoadPolicy(path string) (*RolePolicy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read policy %s: %w", path, err)
+	}
+	var doc struct {
+		Roles map[string][]string `mapstructure:"roles"`
+	}
+	if err := v.Unmarshal(&doc); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+	if len(doc.Roles) == 0 {
+		return nil, fmt.Errorf("policy %s defines no roles", path)
+	}
+	return NewRolePolicy(doc.Roles), nil
+}
+
+func (p *RolePolicy) Can(role, perm string) bool {
+	for _, g := range p.roles[role] {
+		if g == "*" || g == perm {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasPrefix(perm, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionError names the permissions a caller would have needed; holding
+// any one of them would have been enough.
+type PermissionError struct {
+	Missing []string
+}
+
+func (e *PermissionError) Error() string {
+	return "missing permission " + strings.Join(e.Missing, " or ")
+}
+
+// Require checks that the caller in ctx holds at least one of perms. A
+// context without a caller is denied; work outside a request runs as
+// System.
+func Require(ctx context.Context, policy Policy, perms ...string) error {
+	caller := FromContext(ctx)
+	if caller.IsSystem() {
+		return nil
+	}
+	if caller != nil {
+		for _, perm := range perms {
+			if policy.Can(caller.Role, perm) {
+				return nil
+			}
+		}
+	}
+	return &PermissionError{Missing: perms}
+}
+
+// Holds reports whether the caller in ctx holds perm.
+func Holds(ctx context.Context, policy Policy, perm string) bool {
+	return Require(ctx, policy, perm) == nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import "context"
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleReader = "reader"
+	// RoleSystem names System in logs. Permissions follow from being
+	// System, not from the role, which a user could also be given.
+	RoleSystem = "system"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// System is the caller of work the service starts itself: CLI commands
+// and background jobs. It acts as no user.
+var System = &Principal{Role: RoleSystem}
+
+// IsSystem is true only for System itself, not for a user whose role
+// happens to read "system".
+func (p *Principal) IsSystem() bool { return p == System }
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the caller, or nil when there is none. Permission
+// checks deny a context without a caller.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// UserID returns the calling user's id, for recording who authored or
+// edited something. It is false for System and contexts without a caller.
+func UserID(ctx context.Context) (int, bool) {
+	p := FromContext(ctx)
+	if p == nil || p.IsSystem() {
+		return 0, false
+	}
+	return p.UserID, true
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	Role string `json:"role"`
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// TokenManager issues and verifies HS256 tokens signed with JWT_SECRET.
+type TokenManager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenManager(cfg config.Config) (*TokenManager, error) {
+	if cfg.Auth.JWTSecret == "" {
+		return nil, errors.New("JWT_SECRET empty")
+	}
+	return &TokenManager{
+		secret:     []byte(cfg.Auth.JWTSecret),
+		accessTTL:  cfg.Auth.AccessTTL,
+		refreshTTL: cfg.Auth.RefreshTTL,
+	}, nil
+}
+
+func (m *TokenManager) Issue(userID int, role string) (*TokenPair, error) {
+	now := time.Now()
+	access, err := m.sign(userID, role, TokenAccess, now, m.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.sign(userID, role, TokenRefresh, now, m.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresAt:    now.Add(m.accessTTL),
+	}, nil
+}
+
+func (m *TokenManager) sign(userID int, role, typ string, now time.Time, ttl time.Duration) (string, error) {
+	claims := Claims{
+		Role: role,
+		Type: typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// Parse verifies signature, expiry and token type and returns the caller.
+func (m *TokenManager) Parse(token, typ string) (*Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return m.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != typ {
+		return nil, ErrInvalidToken
+	}
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidToken
+	}
+	return &Principal{UserID: id, Role: claims.Role}, nil
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverNone   = "none"
+)
+
+// ErrMiss is returned by Get when the key is absent or expired.
+var ErrMiss = errors.New("cache miss")
+
+// Cache is the small key/value surface the services need. Values are
+// opaque bytes; callers own the encoding.
+type Cache interface {
+	Name() string
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Open builds the cache selected by cfg.Cache.Driver. If Redis is selected
+// but unreachable it falls back to the in-process LRU so the service keeps
+// running, just without a shared cache.
+func Open(cfg config.Config, log *zap.Logger) Cache {
+	switch cfg.Cache.Driver {
+	case DriverNone:
+		log.Info("cache disabled")
+		return NewNoop()
+	case DriverMemory:
+		log.Info("using in-process cache", zap.Int("size", cfg.Cache.LRUSize))
+		return NewLRU(cfg.Cache.LRUSize)
+	}
+	rdb, err := New(cfg, log)
+	if err != nil {
+		log.Warn("redis unavailable, falling back to in-process cache", zap.Error(err))
+		return NewLRU(cfg.Cache.LRUSize)
+	}
+	return NewRedis(rdb)
+}
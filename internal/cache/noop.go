@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// noopCache stores nothing; every Get is a miss.
+type noopCache struct{}
+
+func NewNoop() Cache { return noopCache{} }
+
+func (noopCache) Name() string { return DriverNone }
+
+func (noopCache) Get(context.Context, string) ([]byte, error) { return nil, ErrMiss }
+
+func (noopCache) Set(context.Context, string, []byte, time.Duration) error { return nil }
+
+func (noopCache) Del(context.Context, ...string) error { return nil }
+
+func (noopCache) Ping(context.Context) error { return nil }
+
+func (noopCache) Close() error { return nil }
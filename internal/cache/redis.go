@@ -6,30 +6,64 @@ import (
 	"time"
 
 	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/tracing"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 func New(cfg config.Config, log *zap.Logger) (*redis.Client, error) {
-	if cfg.RedisAddr == "" {
+	if cfg.Redis.Addr == "" {
 		return nil, errors.New("REDIS_ADDR empty")
 	}
 	rdb := redis.NewClient(&redis.Options{
-		Addr: cfg.RedisAddr,
-		DB:   cfg.RedisDB,
+		Addr: cfg.Redis.Addr,
+		DB:   cfg.Redis.DB,
 	})
+	rdb.AddHook(tracing.RedisHook{})
 	if err := rdb.Ping(context.Background()).Err(); err != nil {
 		return nil, err
 	}
-	log.Info("connected to redis", zap.String("addr", cfg.RedisAddr))
+	log.Info("connected to redis", zap.String("addr", cfg.Redis.Addr))
 	return rdb, nil
 }
 
 func TTL(cfg config.Config) time.Duration {
-	ttl := time.Duration(cfg.RedisTTLSeconds) * time.Second
+	ttl := cfg.Cache.TTL
 	if ttl <= 0 {
 		return 5 * time.Minute
 	}
 	return ttl
 }
+
+type redisCache struct {
+	rdb *redis.Client
+}
+
+func NewRedis(rdb *redis.Client) Cache {
+	return &redisCache{rdb: rdb}
+}
+
+func (c *redisCache) Name() string { return DriverRedis }
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := c.rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return b, err
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	return c.rdb.Set(ctx, key, val, ttl).Err()
+}
+
+func (c *redisCache) Del(ctx context.Context, keys ...string) error {
+	return c.rdb.Del(ctx, keys...).Err()
+}
+
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.rdb.Ping(ctx).Err()
+}
+
+func (c *redisCache) Close() error { return c.rdb.Close() }
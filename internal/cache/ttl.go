@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+)
+
+// Jitter spreads ttl by up to ±frac so keys written together do not all
+// expire in the same instant.
+func Jitter(ttl time.Duration, frac float64) time.Duration {
+	if frac <= 0 || ttl <= 0 {
+		return ttl
+	}
+	if frac > 1 {
+		frac = 1
+	}
+	delta := float64(ttl) * frac * (2*rand.Float64() - 1)
+	return ttl + time.Duration(delta)
+}
+
+// NegativeTTL is how long a "not found" answer may be cached.
+func NegativeTTL(cfg config.Config) time.Duration {
+	ttl := cfg.Cache.NegativeTTL
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// StaleWindow is how long past its TTL an entry may still be served while
+// it is refreshed in the background. Zero disables stale-while-revalidate.
+func StaleWindow(cfg config.Config) time.Duration {
+	w := cfg.Cache.StaleWindow
+	if w < 0 {
+		return 0
+	}
+	return w
+}
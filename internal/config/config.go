@@ -1,45 +1,209 @@
+// Package config loads the service configuration. Values are layered, each
+// overriding the one before: built-in defaults, a YAML/TOML/JSON file,
+// environment variables, then command-line flags.
 package config
 
 import (
 	"time"
-
-	"github.com/spf13/viper"
 )
 
 type Config struct {
-	AppPort         string
-	AppEnv          string
-	DatabaseURL     string
-	RedisAddr       string
-	RedisDB         int
-	RedisTTLSeconds int
-	ESAddr          string
-	ESIndex         string
-	Timeout         time.Duration
-}
-
-func Load() Config {
-	v := viper.New()
-	v.SetConfigFile(".env")
-	_ = v.ReadInConfig()
-	v.AutomaticEnv()
-
-	v.SetDefault("APP_PORT", "8080")
-	v.SetDefault("APP_ENV", "development")
-	v.SetDefault("REDIS_DB", 0)
-	v.SetDefault("REDIS_TTL_SECONDS", 300)
-	v.SetDefault("ES_ADDR", "http://localhost:9200")
-	v.SetDefault("ES_INDEX", "posts")
+	// Env is development, production or test.
+	Env string `mapstructure:"env"`
+	// Timeout bounds a single database, cache or search operation.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	HTTP        HTTPConfig        `mapstructure:"http"`
+	DB          DBConfig          `mapstructure:"db"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	Cache       CacheConfig       `mapstructure:"cache"`
+	ES          ESConfig          `mapstructure:"es"`
+	Log         LogConfig         `mapstructure:"log"`
+	Auth        AuthConfig        `mapstructure:"auth"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Shutdown    ShutdownConfig    `mapstructure:"shutdown"`
+	Health      HealthConfig      `mapstructure:"health"`
+	Tracing     TracingConfig     `mapstructure:"tracing"`
+}
+
+type HTTPConfig struct {
+	Port              string        `mapstructure:"port"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+}
+
+type DBConfig struct {
+	URL string `mapstructure:"url" secret:"true"`
+	// ReplicaURLs are read-only replicas; GetByID and tag listings read
+	// from them in turn. Empty reads everything from URL.
+	ReplicaURLs []string `mapstructure:"replica_urls" secret:"true"`
+	AutoMigrate bool     `mapstructure:"auto_migrate"`
+
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	// ConnectTimeout is how long startup keeps retrying while Postgres is
+	// not up yet; zero tries once.
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	// SlowQuery logs statements that take at least this long; zero disables it.
+	SlowQuery time.Duration `mapstructure:"slow_query"`
+	// ReadAfterWrite keeps reads of a just-written post, and the writer's
+	// tag listings, on the primary while replicas catch up.
+	ReadAfterWrite time.Duration `mapstructure:"read_after_write"`
+}
+
+type RedisConfig struct {
+	Addr string `mapstructure:"addr"`
+	DB   int    `mapstructure:"db"`
+}
+
+type CacheConfig struct {
+	// Driver is redis (falling back to memory when unreachable), memory or none.
+	Driver  string        `mapstructure:"driver"`
+	LRUSize int           `mapstructure:"lru_size"`
+	TTL     time.Duration `mapstructure:"ttl"`
+	// NegativeTTL caches "post not found"; zero disables it.
+	NegativeTTL time.Duration `mapstructure:"negative_ttl"`
+	// StaleWindow serves expired posts this long while refreshing; zero disables it.
+	StaleWindow time.Duration `mapstructure:"stale_window"`
+	TTLJitter   float64       `mapstructure:"ttl_jitter"`
+}
+
+type ESConfig struct {
+	Addr         string  `mapstructure:"addr"`
+	Index        string  `mapstructure:"index"`
+	TitleBoost   float64 `mapstructure:"title_boost"`
+	ContentBoost float64 `mapstructure:"content_boost"`
+}
+
+type LogConfig struct {
+	// Level and Format default by Env when empty.
+	Level  string   `mapstructure:"level"`
+	Format string   `mapstructure:"format"`
+	Output []string `mapstructure:"output"`
+	// The first SamplingInitial identical messages per second are kept,
+	// then every SamplingThereafter-th. Zero disables sampling.
+	SamplingInitial    int      `mapstructure:"sampling_initial"`
+	SamplingThereafter int      `mapstructure:"sampling_thereafter"`
+	MaxSizeMB          int      `mapstructure:"max_size_mb"`
+	MaxAgeDays         int      `mapstructure:"max_age_days"`
+	MaxBackups         int      `mapstructure:"max_backups"`
+	Redact             []string `mapstructure:"redact"`
+}
+
+type AuthConfig struct {
+	JWTSecret      string        `mapstructure:"jwt_secret" secret:"true"`
+	AccessTTL      time.Duration `mapstructure:"access_ttl"`
+	RefreshTTL     time.Duration `mapstructure:"refresh_ttl"`
+	RBACPolicyFile string        `mapstructure:"rbac_policy_file"`
+}
+
+type IdempotencyConfig struct {
+	TTL     time.Duration `mapstructure:"ttl"`
+	LockTTL time.Duration `mapstructure:"lock_ttl"`
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	// Retention is how long delivered events are kept; reindex catch-up
+	// replays from them, so it must outlast a reindex.
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+type ShutdownConfig struct {
+	Timeout    time.Duration `mapstructure:"timeout"`
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+}
+
+type HealthConfig struct {
+	Critical     []string      `mapstructure:"critical"`
+	CheckTimeout time.Duration `mapstructure:"check_timeout"`
+	CacheTTL     time.Duration `mapstructure:"cache_ttl"`
+}
+
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp (OTLP over HTTP).
+	Exporter    string  `mapstructure:"exporter"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	// Output is where the stdout exporter writes: stdout, stderr or a file.
+	// It defaults to stderr so spans do not mix with the JSON logs.
+	Output string `mapstructure:"output"`
+	// Endpoint is the OTLP collector's host:port; Insecure sends plain HTTP.
+	Endpoint string `mapstructure:"endpoint"`
+	Insecure bool   `mapstructure:"insecure"`
+}
 
+// Defaults is the bottom configuration layer.
+func Defaults() Config {
 	return Config{
-		AppPort:         v.GetString("APP_PORT"),
-		AppEnv:          v.GetString("APP_ENV"),
-		DatabaseURL:     v.GetString("DATABASE_URL"),
-		RedisAddr:       v.GetString("REDIS_ADDR"),
-		RedisDB:         v.GetInt("REDIS_DB"),
-		RedisTTLSeconds: v.GetInt("REDIS_TTL_SECONDS"),
-		ESAddr:          v.GetString("ES_ADDR"),
-		ESIndex:         v.GetString("ES_INDEX"),
-		Timeout:         5 * time.Second,
+		Env:     "development",
+		Timeout: 5 * time.Second,
+		HTTP: HTTPConfig{
+			Port:              "8080",
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		DB: DBConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+			SlowQuery:       200 * time.Millisecond,
+			ReadAfterWrite:  5 * time.Second,
+		},
+		Cache: CacheConfig{
+			Driver:      "redis",
+			LRUSize:     10000,
+			TTL:         5 * time.Minute,
+			NegativeTTL: 30 * time.Second,
+			TTLJitter:   0.1,
+		},
+		ES: ESConfig{
+			Index:        "posts",
+			TitleBoost:   3,
+			ContentBoost: 1,
+		},
+		Log: LogConfig{
+			Output:             []string{"stdout"},
+			SamplingInitial:    100,
+			SamplingThereafter: 100,
+			MaxSizeMB:          100,
+			MaxAgeDays:         7,
+			MaxBackups:         5,
+			Redact:             []string{"password", "token", "access_token", "refresh_token", "authorization"},
+		},
+		Auth: AuthConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:     24 * time.Hour,
+			LockTTL: 30 * time.Second,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: time.Second,
+			BatchSize:    50,
+			MaxAttempts:  10,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 20 * time.Second,
+		},
+		Health: HealthConfig{
+			Critical:     []string{"db"},
+			CheckTimeout: 2 * time.Second,
+			CacheTTL:     time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "seta-training",
+			SampleRatio: 1,
+			Output:      "stderr",
+			Endpoint:    "localhost:4318",
+		},
 	}
 }
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// EnvConfigFile names the config file when --config is not given.
+const EnvConfigFile = "CONFIG_FILE"
+
+// envOverrides replaces the derived variable name of keys whose name would
+// be too generic on its own.
+var envOverrides = map[string]string{
+	"env":     "APP_ENV",
+	"timeout": "APP_TIMEOUT",
+}
+
+// legacyEnv lists variable names from before configuration had sections.
+// They are still read, below the current names. Unit converts bare numbers
+// of the old *_SECONDS/_MS/... variables into durations.
+var legacyEnv = []struct {
+	name string
+	key  string
+	unit time.Duration
+}{
+	{"APP_PORT", "http.port", 0},
+	{"DATABASE_URL", "db.url", 0},
+	{"REDIS_TTL_SECONDS", "cache.ttl", time.Second},
+	{"CACHE_NEGATIVE_TTL_SECONDS", "cache.negative_ttl", time.Second},
+	{"CACHE_STALE_SECONDS", "cache.stale_window", time.Second},
+	{"JWT_SECRET", "auth.jwt_secret", 0},
+	{"JWT_ACCESS_TTL_MINUTES", "auth.access_ttl", time.Minute},
+	{"JWT_REFRESH_TTL_HOURS", "auth.refresh_ttl", time.Hour},
+	{"RBAC_POLICY_FILE", "auth.rbac_policy_file", 0},
+	{"IDEMPOTENCY_TTL_HOURS", "idempotency.ttl", time.Hour},
+	{"IDEMPOTENCY_LOCK_SECONDS", "idempotency.lock_ttl", time.Second},
+	{"OUTBOX_POLL_INTERVAL_MS", "outbox.poll_interval", time.Millisecond},
+	{"SHUTDOWN_TIMEOUT_SECONDS", "shutdown.timeout", time.Second},
+	{"SHUTDOWN_DRAIN_DELAY_SECONDS", "shutdown.drain_delay", time.Second},
+	{"HEALTH_CHECK_TIMEOUT_MS", "health.check_timeout", time.Millisecond},
+	{"HEALTH_CACHE_MS", "health.cache_ttl", time.Millisecond},
+}
+
+// Keys lists every setting as a dotted path such as "http.port".
+func Keys() []string {
+	var keys []string
+	walk(reflect.TypeOf(Config{}), "", func(key string, _ reflect.StructField) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// EnvName is the environment variable that sets key.
+func EnvName(key string) string {
+	if name, ok := envOverrides[key]; ok {
+		return name
+	}
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Load builds the configuration from defaults, the file named by --config
+// or CONFIG_FILE, the environment and flags, in increasing precedence.
+// Every key can be set as a flag, e.g. --http.port=9090. Flags must come
+// before any subcommand; the arguments after them are returned.
+func Load(args []string) (Config, []string, error) {
+	cfg := Defaults()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(EnvConfigFile), "YAML, TOML or JSON config file")
+	for _, key := range Keys() {
+		fs.String(key, "", "overrides "+key+" (env "+EnvName(key)+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, fmt.Errorf("flags: %w", err)
+	}
+
+	if *path != "" {
+		v := viper.New()
+		v.SetConfigFile(*path)
+		if err := v.ReadInConfig(); err != nil {
+			return cfg, nil, fmt.Errorf("read config file %s: %w", *path, err)
+		}
+		if err := decode(v.AllSettings(), &cfg); err != nil {
+			return cfg, nil, fmt.Errorf("config file %s: %w", *path, err)
+		}
+	}
+
+	env, err := fromEnv()
+	if err != nil {
+		return cfg, nil, err
+	}
+	if err := decode(nest(env), &cfg); err != nil {
+		return cfg, nil, fmt.Errorf("environment: %w", err)
+	}
+
+	flags := map[string]any{}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+	if err := decode(nest(flags), &cfg); err != nil {
+		return cfg, nil, fmt.Errorf("flags: %w", err)
+	}
+	return cfg, fs.Args(), nil
+}
+
+// fromEnv collects the settings present in the environment. Empty
+// variables count as unset.
+func fromEnv() (map[string]any, error) {
+	values := map[string]any{}
+	for _, l := range legacyEnv {
+		raw := os.Getenv(l.name)
+		if raw == "" {
+			continue
+		}
+		if l.unit == 0 {
+			values[l.key] = raw
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("environment: %s: must be a whole number", l.name)
+		}
+		values[l.key] = (time.Duration(n) * l.unit).String()
+	}
+	for _, key := range Keys() {
+		if raw := os.Getenv(EnvName(key)); raw != "" {
+			values[key] = raw
+		}
+	}
+	return values, nil
+}
+
+// nest turns {"http.port": v} into {"http": {"port": v}}.
+func nest(flat map[string]any) map[string]any {
+	out := map[string]any{}
+	for key, v := range flat {
+		m := out
+		parts := strings.Split(key, ".")
+		for _, p := range parts[:len(parts)-1] {
+			sub, ok := m[p].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				m[p] = sub
+			}
+			m = sub
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	return out
+}
+
+// decode overlays the settings in src onto cfg. Lists replace the lower
+// layer's rather than merging with it. Unknown keys are errors so typos in
+// a config file do not pass silently.
+func decode(src map[string]any, cfg *Config) error {
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           cfg,
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		ZeroFields:       true,
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(durationHook, listHook),
+	})
+	if err != nil {
+		return err
+	}
+	return d.Decode(src)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationHook parses durations such as "30s". Bare numbers are rejected
+// rather than read as nanoseconds.
+func durationHook(from, to reflect.Type, data any) (any, error) {
+	if to != durationType {
+		return data, nil
+	}
+	switch from.Kind() {
+	case reflect.String:
+		d, err := time.ParseDuration(strings.TrimSpace(data.(string)))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a duration such as 30s or 5m", data)
+		}
+		return d, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil, fmt.Errorf("%v has no unit, use a duration such as 30s or 5m", data)
+	}
+	return data, nil
+}
+
+// listHook splits comma-separated strings, as given in env and flags.
+func listHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.Slice {
+		return data, nil
+	}
+	var out []string
+	for _, part := range strings.Split(data.(string), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out, nil
+}
+
+// walk calls fn for every leaf setting of t with its dotted key.
+func walk(t reflect.Type, prefix string, fn func(key string, f reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := prefix + f.Tag.Get("mapstructure")
+		if f.Type.Kind() == reflect.Struct {
+			walk(f.Type, key+".", fn)
+			continue
+		}
+		fn(key, f)
+	}
+}
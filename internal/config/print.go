@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const masked = "********"
+
+// Print writes the configuration as YAML in the same shape a config file
+// takes, with secrets masked.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node(reflect.ValueOf(c))); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func node(v reflect.Value) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Tag.Get("mapstructure")}
+		fv := v.Field(i)
+		var val *yaml.Node
+		switch {
+		case fv.Kind() == reflect.Struct:
+			val = node(fv)
+		case f.Tag.Get("secret") == "true" && fv.Kind() == reflect.Slice:
+			val = &yaml.Node{Kind: yaml.SequenceNode}
+			for j := 0; j < fv.Len(); j++ {
+				val.Content = append(val.Content, scalar(maskSecret(fv.Index(j).String())))
+			}
+		case f.Tag.Get("secret") == "true":
+			val = scalar(maskSecret(fv.String()))
+		case fv.Type() == durationType:
+			val = scalar(time.Duration(fv.Int()).String())
+		default:
+			val = &yaml.Node{}
+			if err := val.Encode(fv.Interface()); err != nil {
+				val = scalar(fmt.Sprint(fv.Interface()))
+			}
+		}
+		n.Content = append(n.Content, key, val)
+	}
+	return n
+}
+
+func scalar(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// maskSecret hides a secret but keeps the shape of URLs, so a printed
+// database URL still shows which host it points at. URL passwords read
+// xxxxx as in url.URL.Redacted; "*" would be percent-encoded.
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+		q := u.Query()
+		if q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	return masked
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks the whole configuration and reports every problem at
+// once, one per line.
+func (c Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s (%s): %s", key, EnvName(key), fmt.Sprintf(format, args...)))
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			bad(key, "must be a positive duration, got %s", d)
+		}
+	}
+	nonNegative := func(key string, d time.Duration) {
+		if d < 0 {
+			bad(key, "must not be negative, got %s", d)
+		}
+	}
+	oneOf := func(key, v string, allowed ...string) {
+		for _, a := range allowed {
+			if v == a {
+				return
+			}
+		}
+		bad(key, "must be one of %s, got %q", strings.Join(allowed, ", "), v)
+	}
+
+	oneOf("env", c.Env, "development", "production", "test")
+	positive("timeout", c.Timeout)
+
+	if port, err := strconv.Atoi(c.HTTP.Port); err != nil || port < 1 || port > 65535 {
+		bad("http.port", "must be a port number, got %q", c.HTTP.Port)
+	}
+	positive("http.read_header_timeout", c.HTTP.ReadHeaderTimeout)
+
+	if c.DB.URL == "" {
+		bad("db.url", "is required")
+	}
+	for i, u := range c.DB.ReplicaURLs {
+		// the parse error would repeat the URL, password and all
+		if _, err := url.Parse(u); err != nil {
+			bad("db.replica_urls", "entry %d is not a valid URL", i+1)
+		}
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 {
+		bad("db.max_open_conns", "pool sizes must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		bad("db.max_idle_conns", "must not exceed db.max_open_conns (%d)", c.DB.MaxOpenConns)
+	}
+	nonNegative("db.conn_max_lifetime", c.DB.ConnMaxLifetime)
+	nonNegative("db.conn_max_idle_time", c.DB.ConnMaxIdleTime)
+	nonNegative("db.connect_timeout", c.DB.ConnectTimeout)
+	nonNegative("db.slow_query", c.DB.SlowQuery)
+	nonNegative("db.read_after_write", c.DB.ReadAfterWrite)
+
+	if c.Redis.DB < 0 {
+		bad("redis.db", "must not be negative")
+	}
+
+	oneOf("cache.driver", c.Cache.Driver, "redis", "memory", "none")
+	if c.Cache.LRUSize <= 0 {
+		bad("cache.lru_size", "must be positive")
+	}
+	positive("cache.ttl", c.Cache.TTL)
+	nonNegative("cache.negative_ttl", c.Cache.NegativeTTL)
+	nonNegative("cache.stale_window", c.Cache.StaleWindow)
+	if c.Cache.TTLJitter < 0 || c.Cache.TTLJitter > 1 {
+		bad("cache.ttl_jitter", "must be between 0 and 1")
+	}
+
+	// An empty address disables Elasticsearch and search uses Postgres only
+	if c.ES.Addr != "" {
+		if u, err := url.Parse(c.ES.Addr); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			bad("es.addr", "must be an http(s) URL, got %q", c.ES.Addr)
+		}
+	}
+	if c.ES.Index == "" {
+		bad("es.index", "is required")
+	}
+	if c.ES.TitleBoost <= 0 || c.ES.ContentBoost <= 0 {
+		bad("es.title_boost", "boosts must be positive")
+	}
+
+	if c.Log.Level != "" {
+		oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error", "dpanic", "panic", "fatal")
+	}
+	if c.Log.Format != "" {
+		oneOf("log.format", c.Log.Format, "json", "console")
+	}
+	if len(c.Log.Output) == 0 {
+		bad("log.output", "needs at least one target")
+	}
+	if c.Log.SamplingInitial < 0 || c.Log.SamplingThereafter < 0 {
+		bad("log.sampling_initial", "sampling must not be negative")
+	}
+	if c.Log.MaxSizeMB <= 0 {
+		bad("log.max_size_mb", "must be positive")
+	}
+
+	if c.Auth.JWTSecret == "" {
+		bad("auth.jwt_secret", "is required")
+	} else if c.Env == "production" && len(c.Auth.JWTSecret) < 32 {
+		bad("auth.jwt_secret", "must be at least 32 characters in production")
+	}
+	positive("auth.access_ttl", c.Auth.AccessTTL)
+	positive("auth.refresh_ttl", c.Auth.RefreshTTL)
+
+	positive("idempotency.ttl", c.Idempotency.TTL)
+	positive("idempotency.lock_ttl", c.Idempotency.LockTTL)
+
+	positive("outbox.poll_interval", c.Outbox.PollInterval)
+	if c.Outbox.BatchSize <= 0 {
+		bad("outbox.batch_size", "must be positive")
+	}
+	if c.Outbox.MaxAttempts <= 0 {
+		bad("outbox.max_attempts", "must be positive")
+	}
+	positive("outbox.retention", c.Outbox.Retention)
+
+	positive("shutdown.timeout", c.Shutdown.Timeout)
+	nonNegative("shutdown.drain_delay", c.Shutdown.DrainDelay)
+
+	for _, name := range c.Health.Critical {
+		oneOf("health.critical", name, "db", "cache", "es")
+	}
+	positive("health.check_timeout", c.Health.CheckTimeout)
+	nonNegative("health.cache_ttl", c.Health.CacheTTL)
+
+	oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "otlp")
+	if c.Tracing.Exporter == "stdout" && c.Tracing.Output == "" {
+		bad("tracing.output", "is required for the stdout exporter")
+	}
+	if c.Tracing.Exporter == "otlp" && c.Tracing.Endpoint == "" {
+		bad("tracing.endpoint", "is required for the otlp exporter")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		bad("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	return errors.Join(errs...)
+}
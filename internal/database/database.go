@@ -3,27 +3,126 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/xuanviet96/seta-training/internal/config"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-func Connect(dsn string, log *zap.Logger) (*gorm.DB, error) {
-	if dsn == "" {
-		return nil, errors.New("DATABASE_URL is empty")
+// Connect opens the primary database. While Postgres is not up yet it
+// retries with backoff for up to cfg.ConnectTimeout.
+func Connect(cfg config.DBConfig, log *zap.Logger) (*gorm.DB, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("database url is empty")
+	}
+	if log == nil {
+		log = zap.NewNop()
+	}
+	deadline := time.Now().Add(cfg.ConnectTimeout)
+	wait := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		db, err := open(cfg, cfg.URL, log, false)
+		if err == nil {
+			log.Info("connected to database", zap.Int("attempt", attempt))
+			return db, nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("connect to database after %d attempts: %w", attempt, err)
+		}
+		log.Warn("database not reachable, retrying", zap.Int("attempt", attempt), zap.Duration("retry_in", wait), zap.Error(err))
+		time.Sleep(wait)
+		wait = min(wait*2, 5*time.Second)
+	}
+}
+
+// Initialize opens a DB connection using the provided config.
+func Initialize(cfg config.Config) (*gorm.DB, error) {
+	return Connect(cfg.DB, nil)
+}
+
+// open opens dsn with the configured pool. A lazy database does not
+// connect until its first query.
+func open(cfg config.DBConfig, dsn string, log *zap.Logger, lazy bool) (*gorm.DB, error) {
+	// failed attempts are logged by Connect, not once more by Gorm
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:               gormlogger.Discard,
+		DisableAutomaticPing: lazy,
+	})
+	if db != nil && db.ConnPool != nil && err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			_ = sqlDB.Close()
+		}
 	}
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
+	db.Logger = NewGormLogger(log, cfg.SlowQuery)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	return db, nil
 }
 
-// Initialize opens a DB connection using the provided config.
-func Initialize(cfg config.Config) (*gorm.DB, error) {
-	return Connect(cfg.DatabaseURL, nil)
+// Replicas are read-only copies of the primary, used in turn. They connect
+// lazily, so a replica that is down does not hold up startup; callers fall
+// back to the primary when a replica read fails. A nil *Replicas has none.
+type Replicas struct {
+	dbs  []*gorm.DB
+	next atomic.Uint64
+}
+
+func ConnectReplicas(cfg config.DBConfig, log *zap.Logger) (*Replicas, error) {
+	r := &Replicas{}
+	for i, dsn := range cfg.ReplicaURLs {
+		db, err := open(cfg, dsn, log.With(zap.Int("replica", i+1)), true)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		r.dbs = append(r.dbs, db)
+	}
+	if len(r.dbs) > 0 {
+		log.Info("using read replicas", zap.Int("count", len(r.dbs)))
+	}
+	return r, nil
+}
+
+// Next returns the replica for the next read, or nil without replicas.
+func (r *Replicas) Next() *gorm.DB {
+	if r == nil || len(r.dbs) == 0 {
+		return nil
+	}
+	return r.dbs[(r.next.Add(1)-1)%uint64(len(r.dbs))]
+}
+
+func (r *Replicas) Len() int { return len(r.All()) }
+
+func (r *Replicas) All() []*gorm.DB {
+	if r == nil {
+		return nil
+	}
+	return r.dbs
+}
+
+func (r *Replicas) Close() error {
+	var errs []error
+	for _, db := range r.All() {
+		if sqlDB, err := db.DB(); err == nil {
+			errs = append(errs, sqlDB.Close())
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func Ping(gdb *gorm.DB) error {
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// ReindexLockID is the pg_advisory_lock key held while rebuilding the
+// search index, so the CLI and the API cannot run two rebuilds at once.
+const ReindexLockID int64 = 0x5e7a_0002
+
+// TryLock takes the session advisory lock id on a dedicated connection
+// without waiting. ok is false when another session holds it; otherwise
+// the caller must call unlock, which also returns the connection.
+func TryLock(ctx context.Context, db *gorm.DB, id int64) (unlock func(), ok bool, err error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, id).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, id)
+		conn.Close()
+	}, true, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// gormLogger writes Gorm's log through zap, using the request's logger
+// when there is one so statements carry its request_id. Every statement is
+// logged at debug, failed and slow ones at warn. SQL keeps its
+// placeholders: bound values may be password hashes or tokens.
+type gormLogger struct {
+	log  *zap.Logger
+	slow time.Duration
+}
+
+// NewGormLogger logs statements taking at least slow as slow queries; zero
+// disables that.
+func NewGormLogger(log *zap.Logger, slow time.Duration) gormlogger.Interface {
+	return &gormLogger{log: log, slow: slow}
+}
+
+// LogMode is a no-op: the zap level, adjustable at runtime, decides.
+func (l *gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface { return l }
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...any) {
+	logger.FromContext(ctx, l.log).Sugar().Infof(msg, args...)
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	logger.FromContext(ctx, l.log).Sugar().Warnf(msg, args...)
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...any) {
+	logger.FromContext(ctx, l.log).Sugar().Errorf(msg, args...)
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	log := logger.FromContext(ctx, l.log)
+	elapsed := time.Since(begin)
+	slow := l.slow > 0 && elapsed >= l.slow
+	// not found is an answer the caller handles, not a failure
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	if !slow && !failed && !log.Core().Enabled(zap.DebugLevel) {
+		return
+	}
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.Duration("elapsed", elapsed),
+		// the repository line that ran it; zap's caller would be Gorm
+		zap.String("source", utils.FileWithLineNum()),
+	}
+	switch {
+	case failed:
+		log.Warn("query failed", append(fields, zap.Error(err))...)
+	case slow:
+		log.Warn("slow query", append(fields, zap.Duration("threshold", l.slow))...)
+	default:
+		log.Debug("query", fields...)
+	}
+}
+
+// ParamsFilter drops bound values so they never reach the log.
+func (l *gormLogger) ParamsFilter(_ context.Context, sql string, _ ...any) (string, []any) {
+	return sql, nil
+}
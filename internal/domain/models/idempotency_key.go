@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyDone       = "done"
+)
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header. While the first request runs the row is
+// "processing" and acts as a lock; LockedUntil bounds how long a crashed
+// request can hold it.
+type IdempotencyKey struct {
+	ID              int               `gorm:"primaryKey;autoIncrement"`
+	UserID          int               `gorm:"not null;default:0"`
+	Key             string            `gorm:"size:255"`
+	Fingerprint     string            `gorm:"size:64"`
+	Status          string            `gorm:"default:processing"`
+	ResponseCode    int               `gorm:"not null;default:0"`
+	ResponseHeaders map[string]string `gorm:"serializer:json;type:jsonb"`
+	ResponseBody    []byte
+	LockedUntil     time.Time
+	ExpiresAt       time.Time
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+func (IdempotencyKey) TableName() string { return "idempotency_keys" }
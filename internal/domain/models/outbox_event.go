@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	OutboxOpIndex  = "index"
+	OutboxOpDelete = "delete"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxDone    = "done"
+	OutboxDead    = "dead"
+)
+
+// OutboxEvent is a pending search index operation, written in the same
+// transaction as the post change it mirrors.
+type OutboxEvent struct {
+	ID          int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	Op          string     `json:"op"`
+	PostID      int        `json:"post_id"`
+	Status      string     `json:"status" gorm:"default:pending"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	AvailableAt time.Time  `json:"available_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	// TraceParent links delivery back to the request that wrote the event.
+	TraceParent string `json:"-"`
+}
+
+func (OutboxEvent) TableName() string { return "outbox_events" }
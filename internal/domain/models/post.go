@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/lib/pq"
+	"gorm.io/gorm"
 )
 
 type Post struct {
@@ -11,7 +12,10 @@ type Post struct {
 	Title     string         `json:"title"`
 	Content   string         `json:"content"`
 	Tags      pq.StringArray `json:"tags" gorm:"type:text[]"`
+	AuthorID  *int           `json:"author_id"`
+	Version   int            `json:"version" gorm:"not null;default:1"`
 	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 func (Post) TableName() string { return "posts" }
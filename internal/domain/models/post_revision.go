@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionRollback = "rollback"
+)
+
+// PostRevision is a snapshot of a post's editable fields as of one change.
+// Revisions are numbered from 1 per post.
+type PostRevision struct {
+	ID         int            `json:"id" gorm:"primaryKey;autoIncrement"`
+	PostID     int            `json:"post_id"`
+	Revision   int            `json:"revision"`
+	Title      string         `json:"title"`
+	Content    string         `json:"content"`
+	Tags       pq.StringArray `json:"tags" gorm:"type:text[]"`
+	Action     string         `json:"action"`
+	RollbackOf *int           `json:"rollback_of,omitempty"`
+	EditorID   *int           `json:"editor_id"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (PostRevision) TableName() string { return "post_revisions" }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type User struct {
+	ID           int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email        string    `json:"email" gorm:"uniqueIndex"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (User) TableName() string { return "users" }
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	Claim(ctx context.Context, db *gorm.DB, rec *models.IdempotencyKey) (bool, error)
+	Get(ctx context.Context, db *gorm.DB, userID int, key string) (*models.IdempotencyKey, error)
+	Extend(ctx context.Context, db *gorm.DB, id int, lockedUntil time.Time) (bool, error)
+	Complete(ctx context.Context, db *gorm.DB, id, code int, headers map[string]string, body []byte) error
+	Release(ctx context.Context, db *gorm.DB, id int) error
+	PurgeExpired(ctx context.Context, db *gorm.DB) (int64, error)
+}
+
+type idempotencyRepo struct{}
+
+func NewIdempotencyRepository() IdempotencyRepository { return &idempotencyRepo{} }
+
+// Claim takes the lock on (rec.UserID, rec.Key). It succeeds when the key is
+// new or expired, or when an earlier attempt with the same fingerprint died
+// holding it. On success rec.ID is set.
+func (r *idempotencyRepo) Claim(ctx context.Context, db *gorm.DB, rec *models.IdempotencyKey) (bool, error) {
+	now := time.Now()
+	if err := db.WithContext(ctx).
+		Where("user_id = ? AND key = ? AND expires_at < ?", rec.UserID, rec.Key, now).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return false, err
+	}
+
+	rec.Status = models.IdempotencyProcessing
+	res := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(rec)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// take over a lock whose holder is gone
+	var ids []int
+	err := db.WithContext(ctx).Raw(`
+		UPDATE idempotency_keys SET locked_until = ?
+		WHERE user_id = ? AND key = ? AND fingerprint = ? AND status = ? AND locked_until < ?
+		RETURNING id`,
+		rec.LockedUntil, rec.UserID, rec.Key, rec.Fingerprint, models.IdempotencyProcessing, now,
+	).Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return false, err
+	}
+	rec.ID = ids[0]
+	return true, nil
+}
+
+func (r *idempotencyRepo) Get(ctx context.Context, db *gorm.DB, userID int, key string) (*models.IdempotencyKey, error) {
+	var rec models.IdempotencyKey
+	if err := db.WithContext(ctx).Where("user_id = ? AND key = ?", userID, key).First(&rec).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Extend moves the lock of a claim that is still processing to
+// lockedUntil. It reports false once the claim is gone or complete.
+func (r *idempotencyRepo) Extend(ctx context.Context, db *gorm.DB, id int, lockedUntil time.Time) (bool, error) {
+	res := db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("id = ? AND status = ?", id, models.IdempotencyProcessing).
+		Update("locked_until", lockedUntil)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, db *gorm.DB, id, code int, headers map[string]string, body []byte) error {
+	return db.WithContext(ctx).Model(&models.IdempotencyKey{ID: id}).
+		Select("status", "response_code", "response_headers", "response_body").
+		Updates(&models.IdempotencyKey{
+			Status:          models.IdempotencyDone,
+			ResponseCode:    code,
+			ResponseHeaders: headers,
+			ResponseBody:    body,
+		}).Error
+}
+
+// Release drops a claim so the request can be retried from scratch.
+func (r *idempotencyRepo) Release(ctx context.Context, db *gorm.DB, id int) error {
+	return db.WithContext(ctx).Delete(&models.IdempotencyKey{}, id).Error
+}
+
+func (r *idempotencyRepo) PurgeExpired(ctx context.Context, db *gorm.DB) (int64, error) {
+	res := db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
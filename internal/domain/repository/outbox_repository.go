@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/tracing"
+
+	"gorm.io/gorm"
+)
+
+type OutboxStats struct {
+	Pending       int64      `json:"pending"`
+	Dead          int64      `json:"dead"`
+	OldestPending *time.Time `json:"oldest_pending,omitempty"`
+}
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, tx *gorm.DB, ev *models.OutboxEvent) error
+	Claim(ctx context.Context, db *gorm.DB, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkDone(ctx context.Context, db *gorm.DB, id int) error
+	MarkRetry(ctx context.Context, db *gorm.DB, id int, at time.Time, cause string) error
+	MarkDead(ctx context.Context, db *gorm.DB, id int, cause string) error
+	Requeue(ctx context.Context, db *gorm.DB, id int) error
+	Stats(ctx context.Context, db *gorm.DB) (*OutboxStats, error)
+	PostIDsSince(ctx context.Context, db *gorm.DB, since time.Time) ([]int, error)
+	PurgeDone(ctx context.Context, db *gorm.DB, before time.Time, limit int) (int64, error)
+}
+
+type outboxRepo struct{}
+
+func NewOutboxRepository() OutboxRepository { return &outboxRepo{} }
+
+func (r *outboxRepo) Enqueue(ctx context.Context, tx *gorm.DB, ev *models.OutboxEvent) error {
+	if ev.Status == "" {
+		ev.Status = models.OutboxPending
+	}
+	if ev.AvailableAt.IsZero() {
+		ev.AvailableAt = time.Now()
+	}
+	if ev.TraceParent == "" {
+		ev.TraceParent = tracing.Inject(ctx)
+	}
+	return tx.WithContext(ctx).Create(ev).Error
+}
+
+// Claim leases up to limit due events by pushing their available_at past
+// the lease and bumping attempts. A dispatcher that dies mid-batch simply
+// lets the lease expire and the events are picked up again.
+func (r *outboxRepo) Claim(ctx context.Context, db *gorm.DB, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var evs []models.OutboxEvent
+	err := db.WithContext(ctx).Raw(`
+		UPDATE outbox_events SET available_at = ?, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE status = ? AND available_at <= ?
+			ORDER BY available_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		time.Now().Add(lease), models.OutboxPending, time.Now(), limit,
+	).Scan(&evs).Error
+	return evs, err
+}
+
+func (r *outboxRepo) MarkDone(ctx context.Context, db *gorm.DB, id int) error {
+	return db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status":       models.OutboxDone,
+			"last_error":   "",
+			"processed_at": time.Now(),
+		}).Error
+}
+
+func (r *outboxRepo) MarkRetry(ctx context.Context, db *gorm.DB, id int, at time.Time, cause string) error {
+	return db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"available_at": at,
+			"last_error":   cause,
+		}).Error
+}
+
+func (r *outboxRepo) MarkDead(ctx context.Context, db *gorm.DB, id int, cause string) error {
+	return db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status":       models.OutboxDead,
+			"last_error":   cause,
+			"processed_at": time.Now(),
+		}).Error
+}
+
+// Requeue moves a dead event back to pending with a fresh attempt budget.
+func (r *outboxRepo) Requeue(ctx context.Context, db *gorm.DB, id int) error {
+	res := db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id = ? AND status = ?", id, models.OutboxDead).
+		Updates(map[string]any{
+			"status":       models.OutboxPending,
+			"attempts":     0,
+			"available_at": time.Now(),
+			"processed_at": nil,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *outboxRepo) Stats(ctx context.Context, db *gorm.DB) (*OutboxStats, error) {
+	var st OutboxStats
+	q := db.WithContext(ctx).Model(&models.OutboxEvent{})
+	if err := q.Session(&gorm.Session{}).Where("status = ?", models.OutboxPending).Count(&st.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := q.Session(&gorm.Session{}).Where("status = ?", models.OutboxDead).Count(&st.Dead).Error; err != nil {
+		return nil, err
+	}
+	if st.Pending > 0 {
+		var oldest time.Time
+		if err := q.Session(&gorm.Session{}).Where("status = ?", models.OutboxPending).
+			Select("MIN(created_at)").Scan(&oldest).Error; err != nil {
+			return nil, err
+		}
+		st.OldestPending = &oldest
+	}
+	return &st, nil
+}
+
+// PurgeDone deletes up to limit events delivered before before, oldest
+// first. Dead events are kept for inspection and requeueing.
+func (r *outboxRepo) PurgeDone(ctx context.Context, db *gorm.DB, before time.Time, limit int) (int64, error) {
+	res := db.WithContext(ctx).Exec(`
+		DELETE FROM outbox_events WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE status = ? AND processed_at < ?
+			ORDER BY processed_at
+			LIMIT ?
+		)`,
+		models.OutboxDone, before, limit,
+	)
+	return res.RowsAffected, res.Error
+}
+
+// PostIDsSince returns the distinct posts that had events written at or
+// after since, whatever their delivery status.
+func (r *outboxRepo) PostIDsSince(ctx context.Context, db *gorm.DB, since time.Time) ([]int, error) {
+	var ids []int
+	err := db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("created_at >= ?", since).
+		Distinct("post_id").
+		Pluck("post_id", &ids).Error
+	return ids, err
+}
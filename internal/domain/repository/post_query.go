@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+const (
+	TagModeAny = "any"
+	TagModeAll = "all"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PostFilter narrows a post listing. Zero values mean "no filter".
+type PostFilter struct {
+	Tags        []string
+	TagMode     string // TagModeAny (default) or TagModeAll
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	TitlePrefix string
+}
+
+// PageRequest asks for one page of a keyset-paginated listing ordered by
+// (created_at, id) descending. Cursor is an opaque token from a previous
+// PostPage; empty means the first page.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// PostPage is one page of posts plus the tokens to move around it.
+type PostPage struct {
+	Items      []models.Post `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+}
+
+const (
+	cursorNext = "n"
+	cursorPrev = "p"
+)
+
+// cursor marks a position in the (created_at, id) ordering and which way
+// to read from it.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"i"`
+	Dir       string    `json:"d"`
+}
+
+func encodeCursor(p models.Post, dir string) string {
+	b, _ := json.Marshal(cursor{CreatedAt: p.CreatedAt.UTC(), ID: p.ID, Dir: dir})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	if c.Dir != cursorNext && c.Dir != cursorPrev {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func (pr PageRequest) limit() int {
+	switch {
+	case pr.Limit <= 0:
+		return DefaultPageSize
+	case pr.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return pr.Limit
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("ICT", 7*3600))
+	tests := []struct {
+		name string
+		post models.Post
+		dir  string
+	}{
+		{name: "next", post: models.Post{ID: 42, CreatedAt: at}, dir: cursorNext},
+		{name: "prev", post: models.Post{ID: 1, CreatedAt: at}, dir: cursorPrev},
+		{name: "zero time", post: models.Post{ID: 7}, dir: cursorNext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeCursor(encodeCursor(tt.post, tt.dir))
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if c.ID != tt.post.ID || c.Dir != tt.dir || !c.CreatedAt.Equal(tt.post.CreatedAt) {
+				t.Errorf("got %+v, want id %d dir %q at %v", c, tt.post.ID, tt.dir, tt.post.CreatedAt)
+			}
+			if c.CreatedAt.Location() != time.UTC {
+				t.Errorf("created_at in %v, want UTC", c.CreatedAt.Location())
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "empty", in: ""},
+		{name: "not base64", in: "!!!"},
+		{name: "not json", in: enc("hello")},
+		{name: "missing id", in: enc(`{"t":"2024-01-01T00:00:00Z","d":"n"}`)},
+		{name: "negative id", in: enc(`{"t":"2024-01-01T00:00:00Z","i":-3,"d":"n"}`)},
+		{name: "bad direction", in: enc(`{"t":"2024-01-01T00:00:00Z","i":1,"d":"x"}`)},
+		{name: "missing direction", in: enc(`{"t":"2024-01-01T00:00:00Z","i":1}`)},
+		{name: "bad time", in: enc(`{"t":"yesterday","i":1,"d":"n"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeCursor(tt.in)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("decodeCursor(%q) = %+v, %v; want ErrInvalidCursor", tt.in, c, err)
+			}
+		})
+	}
+}
+
+func TestPageRequestLimit(t *testing.T) {
+	tests := []struct {
+		in, want int
+	}{
+		{in: 0, want: DefaultPageSize},
+		{in: -5, want: DefaultPageSize},
+		{in: 1, want: 1},
+		{in: MaxPageSize, want: MaxPageSize},
+		{in: MaxPageSize + 1, want: MaxPageSize},
+	}
+	for _, tt := range tests {
+		if got := (PageRequest{Limit: tt.in}).limit(); got != tt.want {
+			t.Errorf("limit(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,17 +2,32 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/xuanviet96/seta-training/internal/domain/models"
 
+	"github.com/lib/pq"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrVersionConflict means the post changed since the caller read it.
+var ErrVersionConflict = errors.New("post was modified by another request")
+
 type PostRepository interface {
 	CreateWithLog(ctx context.Context, tx *gorm.DB, p *models.Post, log *models.ActivityLog) error
 	GetByID(ctx context.Context, db *gorm.DB, id int) (*models.Post, error)
-	Update(ctx context.Context, db *gorm.DB, p *models.Post) error
-	SearchByTag(ctx context.Context, db *gorm.DB, tag string) ([]models.Post, error)
+	GetForUpdate(ctx context.Context, tx *gorm.DB, id int) (*models.Post, error)
+	AuthorOf(ctx context.Context, db *gorm.DB, id int) (*int, error)
+	UpdateWithLog(ctx context.Context, tx *gorm.DB, p *models.Post, log *models.ActivityLog) error
+	List(ctx context.Context, db *gorm.DB, f PostFilter, page PageRequest) (*PostPage, error)
+	Stream(ctx context.Context, db *gorm.DB, f PostFilter, fn func(*models.Post) error) error
+	SearchByTag(ctx context.Context, db *gorm.DB, tag string, page PageRequest) (*PostPage, error)
+	Batch(ctx context.Context, db *gorm.DB, afterID, limit int) ([]models.Post, error)
+	DeleteWithLog(ctx context.Context, tx *gorm.DB, id int, log *models.ActivityLog) error
+	RestoreWithLog(ctx context.Context, tx *gorm.DB, id int, log *models.ActivityLog) error
+	Purge(ctx context.Context, db *gorm.DB, id int) error
 }
 
 type postRepo struct{}
@@ -38,21 +53,211 @@ func (r *postRepo) GetByID(ctx context.Context, db *gorm.DB, id int) (*models.Po
 	return &p, nil
 }
 
-func (r *postRepo) Update(ctx context.Context, db *gorm.DB, p *models.Post) error {
-	return db.WithContext(ctx).Model(&models.Post{}).Where("id = ?", p.ID).
+// GetForUpdate reads a post and locks its row until tx ends, so the
+// version it returns is the one an update in tx will overwrite.
+func (r *postRepo) GetForUpdate(ctx context.Context, tx *gorm.DB, id int) (*models.Post, error) {
+	var p models.Post
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AuthorOf returns the author of a post, soft-deleted or not. Posts that
+// predate authentication have a nil author.
+func (r *postRepo) AuthorOf(ctx context.Context, db *gorm.DB, id int) (*int, error) {
+	var p models.Post
+	if err := db.WithContext(ctx).Unscoped().Select("id", "author_id").First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return p.AuthorID, nil
+}
+
+// UpdateWithLog writes p only if the stored version still equals p.Version,
+// then bumps p.Version. A stale version yields ErrVersionConflict.
+func (r *postRepo) UpdateWithLog(ctx context.Context, tx *gorm.DB, p *models.Post, al *models.ActivityLog) error {
+	res := tx.WithContext(ctx).Model(&models.Post{}).Where("id = ? AND version = ?", p.ID, p.Version).
 		Updates(map[string]any{
 			"title":   p.Title,
 			"content": p.Content,
 			"tags":    p.Tags,
-		}).Error
+			"version": gorm.Expr("version + 1"),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var n int64
+		if err := tx.WithContext(ctx).Model(&models.Post{}).Where("id = ?", p.ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return ErrVersionConflict
+	}
+	p.Version++
+	al.PostID = p.ID
+	return tx.WithContext(ctx).Create(al).Error
 }
 
-func (r *postRepo) SearchByTag(ctx context.Context, db *gorm.DB, tag string) ([]models.Post, error) {
+func (r *postRepo) List(ctx context.Context, db *gorm.DB, f PostFilter, page PageRequest) (*PostPage, error) {
+	q := filterPosts(db.WithContext(ctx).Model(&models.Post{}), f)
+
+	var cur *cursor
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cur = c
+	}
+
+	backward := cur != nil && cur.Dir == cursorPrev
+	switch {
+	case cur == nil:
+		q = q.Order("created_at DESC, id DESC")
+	case backward:
+		q = q.Where("(created_at, id) > (?, ?)", cur.CreatedAt, cur.ID).Order("created_at ASC, id ASC")
+	default:
+		q = q.Where("(created_at, id) < (?, ?)", cur.CreatedAt, cur.ID).Order("created_at DESC, id DESC")
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	limit := page.limit()
+	var posts []models.Post
+	if err := q.Limit(limit + 1).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	more := len(posts) > limit
+	if more {
+		posts = posts[:limit]
+	}
+	if backward {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+
+	out := &PostPage{Items: posts}
+	if len(posts) == 0 {
+		return out, nil
+	}
+	first, last := posts[0], posts[len(posts)-1]
+	if backward {
+		out.NextCursor = encodeCursor(last, cursorNext)
+		if more {
+			out.PrevCursor = encodeCursor(first, cursorPrev)
+		}
+	} else {
+		if more {
+			out.NextCursor = encodeCursor(last, cursorNext)
+		}
+		if cur != nil {
+			out.PrevCursor = encodeCursor(first, cursorPrev)
+		}
+	}
+	return out, nil
+}
+
+// filterPosts applies f to a posts query.
+func filterPosts(q *gorm.DB, f PostFilter) *gorm.DB {
+	if len(f.Tags) > 0 {
+		// Both operators can use the GIN index on tags
+		if f.TagMode == TagModeAll {
+			q = q.Where("tags @> ?::text[]", pq.StringArray(f.Tags))
+		} else {
+			q = q.Where("tags && ?::text[]", pq.StringArray(f.Tags))
+		}
+	}
+	if f.CreatedFrom != nil {
+		q = q.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		q = q.Where("created_at < ?", *f.CreatedTo)
+	}
+	if f.TitlePrefix != "" {
+		q = q.Where("title LIKE ? ESCAPE '\\'", likeEscaper.Replace(f.TitlePrefix)+"%")
+	}
+	return q
+}
+
+// Stream calls fn for every live post matching f in id order, reading
+// rows one at a time so memory stays flat however many posts match.
+func (r *postRepo) Stream(ctx context.Context, db *gorm.DB, f PostFilter, fn func(*models.Post) error) error {
+	rows, err := filterPosts(db.WithContext(ctx).Model(&models.Post{}), f).Order("id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.Post
+		if err := db.ScanRows(rows, &p); err != nil {
+			return err
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *postRepo) SearchByTag(ctx context.Context, db *gorm.DB, tag string, page PageRequest) (*PostPage, error) {
+	return r.List(ctx, db, PostFilter{Tags: []string{tag}}, page)
+}
+
+// Batch returns up to limit live posts with id greater than afterID in id
+// order, for walking the whole table in chunks.
+func (r *postRepo) Batch(ctx context.Context, db *gorm.DB, afterID, limit int) ([]models.Post, error) {
 	var posts []models.Post
-	// Use GIN index: WHERE tags @> ARRAY[$1]::text[]
 	err := db.WithContext(ctx).
-		Where("tags @> ARRAY[?]::text[]", tag).
-		Order("id DESC").
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
 		Find(&posts).Error
 	return posts, err
 }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// DeleteWithLog soft-deletes a post by setting deleted_at. Already deleted
+// posts are reported as gorm.ErrRecordNotFound.
+func (r *postRepo) DeleteWithLog(ctx context.Context, tx *gorm.DB, id int, al *models.ActivityLog) error {
+	res := tx.WithContext(ctx).Delete(&models.Post{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	al.PostID = id
+	return tx.WithContext(ctx).Create(al).Error
+}
+
+// RestoreWithLog clears deleted_at on a soft-deleted post.
+func (r *postRepo) RestoreWithLog(ctx context.Context, tx *gorm.DB, id int, al *models.ActivityLog) error {
+	res := tx.WithContext(ctx).Unscoped().Model(&models.Post{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	al.PostID = id
+	return tx.WithContext(ctx).Create(al).Error
+}
+
+// Purge hard-deletes a post whether or not it was soft-deleted first.
+// Activity logs go with it through ON DELETE CASCADE.
+func (r *postRepo) Purge(ctx context.Context, db *gorm.DB, id int) error {
+	res := db.WithContext(ctx).Unscoped().Delete(&models.Post{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
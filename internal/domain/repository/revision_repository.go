@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+
+	"gorm.io/gorm"
+)
+
+type RevisionRepository interface {
+	// Append stores rev as the post's next revision number. Call it in the
+	// transaction that changed the post, after the row is written, so the
+	// post's row lock serializes concurrent appends.
+	Append(ctx context.Context, tx *gorm.DB, rev *models.PostRevision) error
+	List(ctx context.Context, db *gorm.DB, postID int) ([]models.PostRevision, error)
+	Get(ctx context.Context, db *gorm.DB, postID, revision int) (*models.PostRevision, error)
+}
+
+type revisionRepo struct{}
+
+func NewRevisionRepository() RevisionRepository { return &revisionRepo{} }
+
+func (r *revisionRepo) Append(ctx context.Context, tx *gorm.DB, rev *models.PostRevision) error {
+	var next int
+	if err := tx.WithContext(ctx).Model(&models.PostRevision{}).
+		Where("post_id = ?", rev.PostID).
+		Select("COALESCE(MAX(revision), 0) + 1").
+		Scan(&next).Error; err != nil {
+		return err
+	}
+	rev.Revision = next
+	return tx.WithContext(ctx).Create(rev).Error
+}
+
+func (r *revisionRepo) List(ctx context.Context, db *gorm.DB, postID int) ([]models.PostRevision, error) {
+	var revs []models.PostRevision
+	err := db.WithContext(ctx).
+		Where("post_id = ?", postID).
+		Order("revision DESC").
+		Find(&revs).Error
+	return revs, err
+}
+
+func (r *revisionRepo) Get(ctx context.Context, db *gorm.DB, postID, revision int) (*models.PostRevision, error) {
+	var rev models.PostRevision
+	if err := db.WithContext(ctx).
+		Where("post_id = ? AND revision = ?", postID, revision).
+		First(&rev).Error; err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, db *gorm.DB, u *models.User) error
+	GetByID(ctx context.Context, db *gorm.DB, id int) (*models.User, error)
+	GetByEmail(ctx context.Context, db *gorm.DB, email string) (*models.User, error)
+}
+
+type userRepo struct{}
+
+func NewUserRepository() UserRepository { return &userRepo{} }
+
+func (r *userRepo) Create(ctx context.Context, db *gorm.DB, u *models.User) error {
+	return db.WithContext(ctx).Create(u).Error
+}
+
+func (r *userRepo) GetByID(ctx context.Context, db *gorm.DB, id int) (*models.User, error) {
+	var u models.User
+	if err := db.WithContext(ctx).First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, db *gorm.DB, email string) (*models.User, error) {
+	var u models.User
+	if err := db.WithContext(ctx).Where("email = ?", email).First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
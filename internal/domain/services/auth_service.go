@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// dummyHash is compared against when the email is unknown, so the response
+// time does not reveal which emails have accounts. Its cost matches
+// bcrypt.DefaultCost, which Register uses.
+var dummyHash = []byte("$2a$10$m1n3QXx0YbnRulMg1590V.0qTGk0a0CB3xYlqTe1Fh7oti9g9c5Gi")
+
+type AuthService struct {
+	log    *zap.Logger
+	db     *gorm.DB
+	users  repository.UserRepository
+	tokens *auth.TokenManager
+}
+
+func NewAuthService(log *zap.Logger, db *gorm.DB, users repository.UserRepository, tokens *auth.TokenManager) *AuthService {
+	return &AuthService{log: log, db: db, users: users, tokens: tokens}
+}
+
+// Register creates an author account and signs it in.
+func (s *AuthService) Register(ctx context.Context, email, password string) (*models.User, *auth.TokenPair, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if _, err := s.users.GetByEmail(ctx, s.db, email); err == nil {
+		return nil, nil, ErrEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, err
+	}
+	u := &models.User{Email: email, PasswordHash: string(hash), Role: auth.RoleAuthor}
+	if err := s.users.Create(ctx, s.db, u); err != nil {
+		// lost a race with another registration for the same email
+		if _, lookupErr := s.users.GetByEmail(ctx, s.db, email); lookupErr == nil {
+			return nil, nil, ErrEmailTaken
+		}
+		return nil, nil, err
+	}
+
+	tokens, err := s.tokens.Issue(u.ID, u.Role)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, tokens, nil
+}
+
+func (s *AuthService) Login(ctx context.Context, email, password string) (*auth.TokenPair, error) {
+	u, err := s.users.GetByEmail(ctx, s.db, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// spend as long as a wrong password would
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return s.tokens.Issue(u.ID, u.Role)
+}
+
+// Refresh trades a refresh token for a new pair. The user is reloaded so a
+// role change or deleted account takes effect at the next refresh.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
+	p, err := s.tokens.Parse(refreshToken, auth.TokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.users.GetByID(ctx, s.db, p.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.tokens.Issue(u.ID, u.Role)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	search "github.com/xuanviet96/seta-training/internal/search"
+	"github.com/xuanviet96/seta-training/pkg/textdiff"
+
+	"gorm.io/gorm"
+)
+
+// Kind classifies a domain error; the HTTP layer maps each kind to a status.
+type Kind string
+
+const (
+	KindNotFound           Kind = "NOT_FOUND"
+	KindConflict           Kind = "CONFLICT"
+	KindValidation         Kind = "VALIDATION"
+	KindUnprocessable      Kind = "UNPROCESSABLE"
+	KindForbidden          Kind = "FORBIDDEN"
+	KindUnauthorized       Kind = "UNAUTHORIZED"
+	KindPreconditionFailed Kind = "PRECONDITION_FAILED"
+	KindUnavailable        Kind = "UNAVAILABLE"
+)
+
+// InvalidParam describes one rejected input field.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Error is a failure the caller can act on. Its Message is safe to show
+// to clients; the wrapped Err is for logs only.
+type Error struct {
+	Kind    Kind
+	Message string
+	// Params lists the offending fields of a validation error.
+	Params []InvalidParam
+	// Missing lists the permissions behind a forbidden error.
+	Missing []string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func NotFound(format string, args ...any) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...any) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(message string, params ...InvalidParam) *Error {
+	return &Error{Kind: KindValidation, Message: message, Params: params}
+}
+
+func PreconditionFailed(format string, args ...any) *Error {
+	return &Error{Kind: KindPreconditionFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unavailable(format string, args ...any) *Error {
+	return &Error{Kind: KindUnavailable, Message: fmt.Sprintf(format, args...)}
+}
+
+var (
+	ErrInvalidCredentials = &Error{Kind: KindUnauthorized, Message: "invalid email or password"}
+	ErrEmailTaken         = Conflict("email already registered")
+)
+
+// AsError classifies err as a domain error, translating the sentinels of
+// lower layers. It returns nil for unexpected failures, which must not be
+// shown to clients.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var perr *auth.PermissionError
+	switch {
+	case errors.As(err, &perr):
+		return &Error{Kind: KindForbidden, Message: perr.Error(), Missing: perr.Missing, Err: err}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &Error{Kind: KindNotFound, Message: "resource not found", Err: err}
+	case errors.Is(err, repository.ErrVersionConflict):
+		return &Error{Kind: KindConflict, Message: err.Error(), Err: err}
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return &Error{Kind: KindValidation, Message: err.Error(), Params: []InvalidParam{{Name: "cursor", Reason: "malformed or expired"}}, Err: err}
+	case errors.Is(err, search.ErrInvalidSearchAfter):
+		return &Error{Kind: KindValidation, Message: err.Error(), Params: []InvalidParam{{Name: "search_after", Reason: "malformed token"}}, Err: err}
+	case errors.Is(err, search.ErrWindowExceeded):
+		return &Error{Kind: KindValidation, Message: search.ErrWindowExceeded.Error(), Params: []InvalidParam{{Name: "from", Reason: fmt.Sprintf("from+size exceeds %d", search.MaxResultWindow)}}, Err: err}
+	case errors.Is(err, textdiff.ErrTooLarge):
+		return &Error{Kind: KindUnprocessable, Message: fmt.Sprintf("content too large to diff: at most %d lines per revision and %d changed lines", textdiff.MaxLines, textdiff.MaxEdits), Err: err}
+	case errors.Is(err, auth.ErrInvalidToken):
+		return &Error{Kind: KindUnauthorized, Message: err.Error(), Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Kind: KindUnavailable, Message: "request timed out", Err: err}
+	}
+	return nil
+}
+
+// notFound names the missing resource when err is a missing row.
+func notFound(err error, what string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Error{Kind: KindNotFound, Message: what + " not found", Err: err}
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	"github.com/xuanviet96/seta-training/internal/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	ExportNDJSON   = "ndjson"
+	ExportCSV      = "csv"
+	ExportMarkdown = "markdown"
+)
+
+// ExportContentTypes maps each export format to its media type and file
+// extension.
+var ExportContentTypes = map[string][2]string{
+	ExportNDJSON:   {"application/x-ndjson", ".ndjson"},
+	ExportCSV:      {"text/csv; charset=utf-8", ".csv"},
+	ExportMarkdown: {"application/gzip", ".tar.gz"},
+}
+
+// Exporter streams posts out in bulk.
+type Exporter struct {
+	log    *zap.Logger
+	db     *gorm.DB
+	posts  repository.PostRepository
+	policy auth.Policy
+}
+
+func NewExporter(log *zap.Logger, db *gorm.DB, posts repository.PostRepository, policy auth.Policy) *Exporter {
+	return &Exporter{log: log, db: db, posts: posts, policy: policy}
+}
+
+// Export writes every live post matching f to w and returns how many were
+// written. Nothing is written to w before the permission check passes.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, format string, f repository.PostFilter) (int, error) {
+	if err := auth.Require(ctx, e.policy, auth.PermPostExport); err != nil {
+		return 0, err
+	}
+	var enc postEncoder
+	switch format {
+	case ExportNDJSON:
+		enc = &ndjsonEncoder{enc: json.NewEncoder(w)}
+	case ExportCSV:
+		enc = newCSVEncoder(w)
+	case ExportMarkdown:
+		enc = newMarkdownEncoder(w)
+	default:
+		return 0, Validation("unsupported export format", InvalidParam{Name: "format", Reason: "must be ndjson, csv or markdown"})
+	}
+
+	n := 0
+	err := e.posts.Stream(ctx, e.db, f, func(p *models.Post) error {
+		n++
+		return enc.Encode(p)
+	})
+	if cerr := enc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logger.FromContext(ctx, e.log).Warn("export aborted", zap.String("format", format), zap.Int("written", n), zap.Error(err))
+		return n, err
+	}
+	logger.FromContext(ctx, e.log).Info("export finished", zap.String("format", format), zap.Int("posts", n))
+	return n, nil
+}
+
+type postEncoder interface {
+	Encode(p *models.Post) error
+	Close() error
+}
+
+type ndjsonEncoder struct{ enc *json.Encoder }
+
+func (e *ndjsonEncoder) Encode(p *models.Post) error { return e.enc.Encode(p) }
+func (e *ndjsonEncoder) Close() error                { return nil }
+
+// csvEncoder writes the columns the importer reads back, plus id and
+// created_at.
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder { return &csvEncoder{w: csv.NewWriter(w)} }
+
+func (e *csvEncoder) Encode(p *models.Post) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write([]string{"id", "title", "content", "tags", "created_at"}); err != nil {
+			return err
+		}
+	}
+	return e.w.Write([]string{
+		strconv.Itoa(p.ID), p.Title, p.Content,
+		strings.Join(p.Tags, ","), p.CreatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// markdownEncoder writes a tar.gz with one posts/<id>-<slug>.md per post.
+type markdownEncoder struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newMarkdownEncoder(w io.Writer) *markdownEncoder {
+	gz := gzip.NewWriter(w)
+	return &markdownEncoder{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+func (e *markdownEncoder) Encode(p *models.Post) error {
+	body := markdown(p)
+	hdr := &tar.Header{
+		Name:    fmt.Sprintf("posts/%d-%s.md", p.ID, slug(p.Title)),
+		Mode:    0o644,
+		Size:    int64(len(body)),
+		ModTime: p.CreatedAt,
+	}
+	if err := e.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := e.tw.Write(body)
+	return err
+}
+
+func (e *markdownEncoder) Close() error {
+	if err := e.tw.Close(); err != nil {
+		return err
+	}
+	return e.gz.Close()
+}
+
+// markdown renders p with YAML front matter. Strings are written as JSON,
+// which YAML reads as double-quoted scalars.
+func markdown(p *models.Post) []byte {
+	title, _ := json.Marshal(p.Title)
+	tags, _ := json.Marshal([]string(p.Tags))
+	if p.Tags == nil {
+		tags = []byte("[]")
+	}
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %d\n", p.ID)
+	fmt.Fprintf(&b, "title: %s\n", title)
+	fmt.Fprintf(&b, "tags: %s\n", tags)
+	fmt.Fprintf(&b, "created_at: %s\n", p.CreatedAt.UTC().Format(time.RFC3339))
+	b.WriteString("---\n\n")
+	b.WriteString(p.Content)
+	if !strings.HasSuffix(p.Content, "\n") {
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(title string) string {
+	s := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(s) > 60 {
+		s = strings.TrimRight(s[:60], "-")
+	}
+	if s == "" {
+		return "post"
+	}
+	return s
+}
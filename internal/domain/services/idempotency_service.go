@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	"github.com/xuanviet96/seta-training/internal/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdempotencyKeyReused  = &Error{Kind: KindUnprocessable, Message: "Idempotency-Key was already used with a different request"}
+	ErrIdempotencyInProgress = Conflict("a request with this Idempotency-Key is still in progress")
+)
+
+// idempotencyPurgeEvery spaces out sweeps of expired keys.
+const idempotencyPurgeEvery = 10 * time.Minute
+
+type IdempotencyService struct {
+	cfg       config.Config
+	log       *zap.Logger
+	db        *gorm.DB
+	repo      repository.IdempotencyRepository
+	lastPurge atomic.Int64
+}
+
+func NewIdempotencyService(cfg config.Config, log *zap.Logger, db *gorm.DB, repo repository.IdempotencyRepository) *IdempotencyService {
+	return &IdempotencyService{cfg: cfg, log: log, db: db, repo: repo}
+}
+
+// Begin claims key for the caller. If an earlier request with the key has
+// finished, its record is returned for replay instead. While another
+// request holds the key, Begin waits for it up to the configured timeout.
+// A claim must be held with Hold while the request runs and ended with
+// Complete or Release.
+func (s *IdempotencyService) Begin(ctx context.Context, key, fingerprint string) (claim, replay *models.IdempotencyKey, err error) {
+	userID := 0
+	if caller := auth.FromContext(ctx); caller != nil {
+		userID = caller.UserID
+	}
+	s.purge(ctx)
+
+	deadline := time.Now().Add(s.cfg.Timeout)
+	wait := 25 * time.Millisecond
+	for {
+		now := time.Now()
+		rec := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			LockedUntil: now.Add(s.cfg.Idempotency.LockTTL),
+			ExpiresAt:   now.Add(s.cfg.Idempotency.TTL),
+		}
+		ok, err := s.repo.Claim(ctx, s.db, rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return rec, nil, nil
+		}
+
+		cur, err := s.repo.Get(ctx, s.db, userID, key)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// released between our claim and read, try again
+			continue
+		case err != nil:
+			return nil, nil, err
+		case cur.Fingerprint != fingerprint:
+			return nil, nil, ErrIdempotencyKeyReused
+		case cur.Status == models.IdempotencyDone:
+			return nil, cur, nil
+		}
+
+		if now.Add(wait).After(deadline) {
+			return nil, nil, ErrIdempotencyInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait < 400*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// Hold renews the claim's lock every third of idempotency.lock_ttl until
+// the returned stop is called. Without it a request running longer than
+// the lock TTL would look crashed, and a retry would take the key over and
+// run the request a second time. Call stop before Complete or Release.
+func (s *IdempotencyService) Hold(ctx context.Context, claim *models.IdempotencyKey) (stop func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(s.cfg.Idempotency.LockTTL / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			ok, err := s.repo.Extend(ctx, s.db, claim.ID, time.Now().Add(s.cfg.Idempotency.LockTTL))
+			switch {
+			case err != nil && ctx.Err() == nil:
+				logger.FromContext(ctx, s.log).Warn("idempotency lock renewal failed", zap.String("key", claim.Key), zap.Error(err))
+			case err == nil && !ok:
+				logger.FromContext(ctx, s.log).Warn("idempotency lock lost", zap.String("key", claim.Key))
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Complete stores the response for replay and releases the lock.
+func (s *IdempotencyService) Complete(ctx context.Context, claim *models.IdempotencyKey, code int, headers map[string]string, body []byte) {
+	// the client going away must not leave the key locked
+	ctx = context.WithoutCancel(ctx)
+	if err := s.repo.Complete(ctx, s.db, claim.ID, code, headers, body); err != nil {
+		logger.FromContext(ctx, s.log).Warn("idempotency complete failed", zap.String("key", claim.Key), zap.Error(err))
+	}
+}
+
+// Release forgets the claim so the request can be retried with the same key.
+func (s *IdempotencyService) Release(ctx context.Context, claim *models.IdempotencyKey) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.repo.Release(ctx, s.db, claim.ID); err != nil {
+		logger.FromContext(ctx, s.log).Warn("idempotency release failed", zap.String("key", claim.Key), zap.Error(err))
+	}
+}
+
+func (s *IdempotencyService) purge(ctx context.Context) {
+	last := s.lastPurge.Load()
+	now := time.Now().UnixNano()
+	if now-last < int64(idempotencyPurgeEvery) || !s.lastPurge.CompareAndSwap(last, now) {
+		return
+	}
+	if n, err := s.repo.PurgeExpired(ctx, s.db); err != nil {
+		logger.FromContext(ctx, s.log).Warn("idempotency purge failed", zap.Error(err))
+	} else if n > 0 {
+		logger.FromContext(ctx, s.log).Info("purged expired idempotency keys", zap.Int64("count", n))
+	}
+}
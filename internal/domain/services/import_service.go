@@ -0,0 +1,390 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	"github.com/xuanviet96/seta-training/internal/logger"
+	search "github.com/xuanviet96/seta-training/internal/search"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	ImportNDJSON = "ndjson"
+	ImportCSV    = "csv"
+)
+
+const (
+	RowInvalid = "invalid"
+	RowFailed  = "failed"
+)
+
+const defaultImportBatch = 100
+
+// maxReportRows caps the rows listed in a report; further ones are only
+// counted in RowsOmitted.
+const maxReportRows = 1000
+
+type ImportOptions struct {
+	Format    string
+	DryRun    bool
+	BatchSize int
+}
+
+// ImportRow is the outcome for one input record that was not imported.
+// Row counts records from 1, not counting a CSV header.
+type ImportRow struct {
+	Row    int            `json:"row"`
+	Status string         `json:"status"`
+	Errors []InvalidParam `json:"errors,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun bool `json:"dry_run"`
+	Total  int  `json:"total"`
+	// Valid counts records that passed validation, whether or not they
+	// were then created.
+	Valid       int    `json:"valid"`
+	Created     int    `json:"created"`
+	Invalid     int    `json:"invalid"`
+	Failed      int    `json:"failed"`
+	IndexFailed int    `json:"index_failed"`
+	Took        string `json:"took"`
+	// Aborted is set when the input could not be read to the end. Records
+	// before the failure were processed, later ones were not.
+	Aborted     string      `json:"aborted,omitempty"`
+	Rows        []ImportRow `json:"rows"`
+	RowsOmitted int         `json:"rows_omitted,omitempty"`
+}
+
+// add lists an invalid or failed row, up to maxReportRows.
+func (rep *ImportReport) add(row ImportRow) {
+	if len(rep.Rows) >= maxReportRows {
+		rep.RowsOmitted++
+		return
+	}
+	rep.Rows = append(rep.Rows, row)
+}
+
+type importRecord struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+}
+
+// Importer creates posts in bulk from NDJSON or CSV.
+type Importer struct {
+	cfg       config.Config
+	log       *zap.Logger
+	db        *gorm.DB
+	posts     repository.PostRepository
+	revisions repository.RevisionRepository
+	outbox    repository.OutboxRepository
+	es        *search.ESClient
+	policy    auth.Policy
+	// svc invalidates cached posts after each batch, as for a single create
+	svc *PostService
+}
+
+func NewImporter(cfg config.Config, log *zap.Logger, db *gorm.DB, posts repository.PostRepository, revisions repository.RevisionRepository, outbox repository.OutboxRepository, es *search.ESClient, policy auth.Policy, svc *PostService) *Importer {
+	return &Importer{cfg: cfg, log: log, db: db, posts: posts, revisions: revisions, outbox: outbox, es: es, policy: policy, svc: svc}
+}
+
+// Run validates every record of r and, unless DryRun is set, inserts the
+// valid ones in transactions of BatchSize. A row that fails to insert is
+// rolled back alone. Each batch is indexed with one _bulk request; index
+// events stay in the outbox for the dispatcher when that fails.
+//
+// The report lists only invalid and failed rows. If r fails partway, the
+// records read so far are still imported and Run returns the report,
+// marked Aborted, together with the read error.
+func (im *Importer) Run(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	if err := auth.Require(ctx, im.policy, auth.PermPostImport); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatch
+	}
+	var next func() (*importRecord, error)
+	switch opts.Format {
+	case ImportNDJSON:
+		next = ndjsonReader(r)
+	case ImportCSV:
+		var err error
+		if next, err = csvReader(r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, Validation("unsupported import format", InvalidParam{Name: "format", Reason: "must be ndjson or csv"})
+	}
+
+	started := time.Now()
+	rep := &ImportReport{DryRun: opts.DryRun, Rows: []ImportRow{}}
+	var batch []*models.Post
+	var batchRows []int
+	var readErr error
+	flush := func() {
+		if len(batch) > 0 {
+			im.insert(ctx, rep, batch, batchRows)
+			batch, batchRows = batch[:0], batchRows[:0]
+		}
+	}
+
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			break
+		}
+		var perr *parseError
+		if err != nil && !errors.As(err, &perr) {
+			readErr = err
+			break
+		}
+		rep.Total++
+		row := ImportRow{Row: rep.Total}
+		if perr != nil {
+			row.Status, row.Error = RowInvalid, perr.Error()
+		} else {
+			row.Errors = validateRecord(rec)
+			if len(row.Errors) > 0 {
+				row.Status = RowInvalid
+			}
+		}
+		if row.Status == RowInvalid {
+			rep.Invalid++
+			rep.add(row)
+			continue
+		}
+		rep.Valid++
+		if opts.DryRun {
+			continue
+		}
+
+		batch = append(batch, recordPost(ctx, rec))
+		batchRows = append(batchRows, row.Row)
+		if len(batch) == opts.BatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	rep.Took = time.Since(started).Round(time.Millisecond).String()
+	if readErr != nil {
+		rep.Aborted = "input unreadable after row " + strconv.Itoa(rep.Total) + ": " + readErr.Error()
+	}
+	logger.FromContext(ctx, im.log).Info("import finished",
+		zap.Bool("dry_run", opts.DryRun), zap.Int("total", rep.Total), zap.Int("created", rep.Created),
+		zap.Int("invalid", rep.Invalid), zap.Int("failed", rep.Failed), zap.Bool("aborted", readErr != nil))
+	return rep, readErr
+}
+
+// insert writes one batch; rows holds the record number of each post.
+func (im *Importer) insert(ctx context.Context, rep *ImportReport, batch []*models.Post, rows []int) {
+	events := make(map[int]int, len(batch))
+	failed := make([]bool, len(batch))
+	err := im.db.Transaction(func(tx *gorm.DB) error {
+		for i, p := range batch {
+			// a savepoint per row keeps one bad row from sinking the batch
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				al := &models.ActivityLog{Action: "new_post", LoggedAt: time.Now()}
+				if err := im.posts.CreateWithLog(ctx, tx, p, al); err != nil {
+					return err
+				}
+				if err := im.revisions.Append(ctx, tx, revisionOf(ctx, p, models.RevisionCreate, nil)); err != nil {
+					return err
+				}
+				// without Elasticsearch nothing would deliver the event
+				if im.cfg.ES.Addr == "" {
+					return nil
+				}
+				ev := &models.OutboxEvent{Op: models.OutboxOpIndex, PostID: p.ID}
+				if err := im.outbox.Enqueue(ctx, tx, ev); err != nil {
+					return err
+				}
+				events[p.ID] = ev.ID
+				return nil
+			})
+			if err != nil {
+				failed[i] = true
+				logger.FromContext(ctx, im.log).Warn("import row failed", zap.Int("row", rows[i]), zap.Error(err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.FromContext(ctx, im.log).Error("import batch failed", zap.Error(err))
+	}
+
+	var docs []search.PostDoc
+	for i, p := range batch {
+		switch {
+		case err != nil, failed[i]:
+			rep.Failed++
+			rep.add(ImportRow{Row: rows[i], Status: RowFailed, Error: "insert failed"})
+		default:
+			rep.Created++
+			// a cached "not found" for the id would hide the new post
+			im.svc.wrote(ctx, p.ID)
+			docs = append(docs, toDoc(*p))
+		}
+	}
+	if len(docs) > 0 && im.cfg.ES.Addr != "" {
+		rep.IndexFailed += im.index(ctx, docs, events)
+	}
+}
+
+// index bulk-indexes docs and marks their outbox events done. Documents
+// that failed keep a pending event and are retried by the dispatcher.
+func (im *Importer) index(ctx context.Context, docs []search.PostDoc, events map[int]int) int {
+	if im.es == nil || !im.es.Ready() {
+		return len(docs)
+	}
+	failed, err := search.BulkIndex(ctx, im.es, im.cfg.ES.Index, docs)
+	if err != nil {
+		logger.FromContext(ctx, im.log).Warn("import bulk index failed, leaving it to the outbox", zap.Error(err))
+		return len(docs)
+	}
+	for _, d := range docs {
+		if _, bad := failed[d.ID]; bad {
+			continue
+		}
+		if err := im.outbox.MarkDone(ctx, im.db, events[d.ID]); err != nil {
+			logger.FromContext(ctx, im.log).Warn("mark outbox event done failed", zap.Int("post_id", d.ID), zap.Error(err))
+		}
+	}
+	return len(failed)
+}
+
+func recordPost(ctx context.Context, rec *importRecord) *models.Post {
+	p := &models.Post{
+		Title:   strings.TrimSpace(rec.Title),
+		Content: strings.TrimSpace(rec.Content),
+		Tags:    pq.StringArray{},
+	}
+	for _, t := range rec.Tags {
+		if t = strings.TrimSpace(t); t != "" {
+			p.Tags = append(p.Tags, t)
+		}
+	}
+	if userID, ok := auth.UserID(ctx); ok {
+		p.AuthorID = &userID
+	}
+	return p
+}
+
+// validateRecord applies the same rules as creating a single post.
+func validateRecord(rec *importRecord) []InvalidParam {
+	var errs []InvalidParam
+	if strings.TrimSpace(rec.Title) == "" {
+		errs = append(errs, InvalidParam{Name: "title", Reason: "is required"})
+	}
+	if strings.TrimSpace(rec.Content) == "" {
+		errs = append(errs, InvalidParam{Name: "content", Reason: "is required"})
+	}
+	return errs
+}
+
+// parseError is a record that could not be decoded; the import skips it
+// and carries on with the next one.
+type parseError struct{ err error }
+
+func (e *parseError) Error() string { return "malformed record: " + e.err.Error() }
+
+// ndjsonReader yields one record per non-blank line.
+func ndjsonReader(r io.Reader) func() (*importRecord, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	return func() (*importRecord, error) {
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			var rec importRecord
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return nil, &parseError{err}
+			}
+			return &rec, nil
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// csvReader expects a header naming title, content and optionally tags, in
+// any order. Tags are comma separated within their field.
+func csvReader(r io.Reader) (func() (*importRecord, error), error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return func() (*importRecord, error) { return nil, io.EOF }, nil
+	}
+	if err != nil {
+		return nil, Validation("malformed CSV header: " + err.Error())
+	}
+	cols := map[string]int{"title": -1, "content": -1, "tags": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := cols[name]; ok {
+			cols[name] = i
+		}
+	}
+	if cols["title"] < 0 || cols["content"] < 0 {
+		return nil, Validation("CSV header must name title and content columns")
+	}
+	field := func(rec []string, name string) string {
+		if i := cols[name]; i >= 0 && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+	return func() (*importRecord, error) {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return nil, &parseError{err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		out := &importRecord{Title: field(rec, "title"), Content: field(rec, "content")}
+		if tags := field(rec, "tags"); tags != "" {
+			out.Tags = strings.Split(tags, ",")
+		}
+		return out, nil
+	}, nil
+}
+
+// ImportFormat picks a format from a Content-Type or file name, or "".
+func ImportFormat(hint string) string {
+	hint = strings.ToLower(hint)
+	switch {
+	case strings.Contains(hint, "ndjson"), strings.Contains(hint, "jsonl"), strings.HasSuffix(hint, ".json"):
+		return ImportNDJSON
+	case strings.Contains(hint, "csv"):
+		return ImportCSV
+	}
+	return ""
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	"github.com/xuanviet96/seta-training/internal/metrics"
+	search "github.com/xuanviet96/seta-training/internal/search"
+	"github.com/xuanviet96/seta-training/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// outboxPurgeEvery spaces out sweeps of delivered events, and
+// outboxPurgeBatch bounds the rows one delete removes.
+const (
+	outboxPurgeEvery = 10 * time.Minute
+	outboxPurgeBatch = 1000
+)
+
+// OutboxDispatcher delivers outbox events to Elasticsearch, retrying
+// failures with exponential backoff until they succeed or run out of
+// attempts and are parked as dead. Delivered events are deleted once
+// they are older than outbox.retention.
+type OutboxDispatcher struct {
+	cfg    config.Config
+	log    *zap.Logger
+	db     *gorm.DB
+	outbox repository.OutboxRepository
+	posts  repository.PostRepository
+	es     *search.ESClient
+
+	delivered atomic.Int64
+	retried   atomic.Int64
+	dead      atomic.Int64
+
+	// lastPurge is only touched by the Run goroutine
+	lastPurge time.Time
+}
+
+type OutboxMetrics struct {
+	repository.OutboxStats
+	LagSeconds float64 `json:"lag_seconds"`
+	Delivered  int64   `json:"delivered"`
+	Retried    int64   `json:"retried"`
+	DeadTotal  int64   `json:"dead_total"`
+}
+
+func NewOutboxDispatcher(cfg config.Config, log *zap.Logger, db *gorm.DB, outbox repository.OutboxRepository, posts repository.PostRepository, es *search.ESClient) *OutboxDispatcher {
+	return &OutboxDispatcher{cfg: cfg, log: log, db: db, outbox: outbox, posts: posts, es: es}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	t := time.NewTicker(d.cfg.Outbox.PollInterval)
+	defer t.Stop()
+	for {
+		// drain everything that is due before sleeping again. A claimed
+		// batch is finished even once shutdown begins, so its events are
+		// not left leased and retried late.
+		for ctx.Err() == nil {
+			n, err := d.dispatch(context.WithoutCancel(ctx))
+			if err != nil {
+				d.log.Warn("outbox dispatch failed", zap.Error(err))
+			}
+			if err != nil || n < d.cfg.Outbox.BatchSize {
+				break
+			}
+		}
+		d.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// purge deletes delivered events past retention, at most every
+// outboxPurgeEvery, in batches so no single delete holds locks for long.
+func (d *OutboxDispatcher) purge(ctx context.Context) {
+	if time.Since(d.lastPurge) < outboxPurgeEvery {
+		return
+	}
+	d.lastPurge = time.Now()
+	before := d.lastPurge.Add(-d.cfg.Outbox.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		n, err := d.outbox.PurgeDone(ctx, d.db, before, outboxPurgeBatch)
+		if err != nil {
+			d.log.Warn("outbox purge failed", zap.Error(err))
+			break
+		}
+		total += n
+		if n < outboxPurgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		d.log.Info("purged delivered outbox events", zap.Int64("count", total))
+	}
+}
+
+func (d *OutboxDispatcher) dispatch(ctx context.Context) (int, error) {
+	// Lease long enough to cover every event in the batch timing out
+	lease := time.Duration(d.cfg.Outbox.BatchSize+1) * d.cfg.Timeout
+	evs, err := d.outbox.Claim(ctx, d.db, d.cfg.Outbox.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, ev := range evs {
+		d.deliver(ctx, ev)
+	}
+	return len(evs), nil
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, ev models.OutboxEvent) {
+	metrics.IndexJobsInFlight.Inc()
+	defer metrics.IndexJobsInFlight.Dec()
+
+	// a new trace per delivery, linked to the request that wrote the event
+	ctx, span := tracing.Tracer().Start(ctx, "outbox.deliver",
+		trace.WithLinks(tracing.LinkFrom(ev.TraceParent)...),
+		trace.WithAttributes(
+			attribute.Int("outbox.event_id", ev.ID),
+			attribute.String("outbox.op", ev.Op),
+			attribute.Int("post.id", ev.PostID),
+			attribute.Int("outbox.attempts", ev.Attempts),
+		),
+	)
+	ctx2, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	err := d.apply(ctx2, ev)
+	cancel()
+	tracing.End(span, err)
+
+	if err == nil {
+		if err := d.outbox.MarkDone(ctx, d.db, ev.ID); err != nil {
+			d.log.Warn("outbox mark done failed", zap.Int("event_id", ev.ID), zap.Error(err))
+			return
+		}
+		d.delivered.Add(1)
+		return
+	}
+
+	log := d.log.With(zap.Int("event_id", ev.ID), zap.String("op", ev.Op), zap.Int("post_id", ev.PostID), zap.Int("attempts", ev.Attempts), zap.Error(err))
+	if ev.Attempts >= d.cfg.Outbox.MaxAttempts || errors.Is(err, errUnknownOutboxOp) {
+		if err := d.outbox.MarkDead(ctx, d.db, ev.ID, err.Error()); err != nil {
+			log.Warn("outbox mark dead failed", zap.NamedError("mark_error", err))
+			return
+		}
+		d.dead.Add(1)
+		log.Error("outbox event dead-lettered")
+		return
+	}
+	if err := d.outbox.MarkRetry(ctx, d.db, ev.ID, time.Now().Add(d.backoff(ev.Attempts)), err.Error()); err != nil {
+		log.Warn("outbox mark retry failed", zap.NamedError("mark_error", err))
+		return
+	}
+	d.retried.Add(1)
+	log.Warn("outbox event failed, will retry")
+}
+
+var errUnknownOutboxOp = errors.New("unknown outbox op")
+
+func (d *OutboxDispatcher) apply(ctx context.Context, ev models.OutboxEvent) error {
+	switch ev.Op {
+	case models.OutboxOpIndex:
+		// Index whatever the row looks like now; if it was deleted since the
+		// event was written, the index should not have it either.
+		p, err := d.posts.GetByID(ctx, d.db, ev.PostID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return search.DeletePost(ctx, d.es, d.cfg.ES.Index, ev.PostID)
+		}
+		if err != nil {
+			return err
+		}
+		return search.IndexPost(ctx, d.es, d.cfg.ES.Index, toDoc(*p))
+	case models.OutboxOpDelete:
+		return search.DeletePost(ctx, d.es, d.cfg.ES.Index, ev.PostID)
+	}
+	return errUnknownOutboxOp
+}
+
+// backoff doubles from one second per attempt, capped at five minutes,
+// with up to 20% jitter so retries from a burst spread out.
+func (d *OutboxDispatcher) backoff(attempt int) time.Duration {
+	b := 5 * time.Minute
+	if attempt < 9 {
+		b = time.Second << attempt
+	}
+	return b + time.Duration(rand.Int63n(int64(b)/5+1))
+}
+
+func (d *OutboxDispatcher) Metrics(ctx context.Context) (*OutboxMetrics, error) {
+	st, err := d.outbox.Stats(ctx, d.db)
+	if err != nil {
+		return nil, err
+	}
+	m := &OutboxMetrics{
+		OutboxStats: *st,
+		Delivered:   d.delivered.Load(),
+		Retried:     d.retried.Load(),
+		DeadTotal:   d.dead.Load(),
+	}
+	if st.OldestPending != nil {
+		m.LagSeconds = time.Since(*st.OldestPending).Seconds()
+	}
+	return m, nil
+}
+
+func (d *OutboxDispatcher) Requeue(ctx context.Context, id int) error {
+	return notFound(d.outbox.Requeue(ctx, d.db, id), "dead outbox event")
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/cache"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/logger"
+	"github.com/xuanviet96/seta-training/internal/metrics"
+	"github.com/xuanviet96/seta-training/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// cachedPost is what GetByID stores under post:<id>. A nil Post records
+// that the id does not exist. FreshUntil marks when the entry turns stale;
+// the cache TTL itself runs on for the stale window. AfterWrite marks a
+// post just written: it is not an answer, only a note to read the post
+// from the primary rather than a replica that may lag.
+type cachedPost struct {
+	Post       *models.Post `json:"p"`
+	FreshUntil time.Time    `json:"f"`
+	AfterWrite bool         `json:"w,omitempty"`
+}
+
+func postKey(id int) string { return fmt.Sprintf("post:%d", id) }
+
+func writerKey(userID int) string { return fmt.Sprintf("post-writer:%d", userID) }
+
+// genKey holds a token that every write of post id replaces. A load that
+// sees the token change while it ran may hold the row from before the
+// write, so it does not backfill the cache.
+func genKey(id int) string { return fmt.Sprintf("post-gen:%d", id) }
+
+func (s *PostService) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostRead); err != nil {
+		return nil, err
+	}
+	key := postKey(id)
+
+	e, ok := s.readPost(ctx, key)
+	if ok {
+		if !time.Now().After(e.FreshUntil) {
+			metrics.PostCacheRequests.WithLabelValues("hit").Inc()
+		} else {
+			metrics.PostCacheRequests.WithLabelValues("stale").Inc()
+			s.refresh(ctx, key, id)
+		}
+		if e.Post == nil {
+			return nil, notFound(gorm.ErrRecordNotFound, "post")
+		}
+		return e.Post, nil
+	}
+
+	p, err := s.loadPost(ctx, key, id, e != nil && e.AfterWrite)
+	if err != nil {
+		return nil, notFound(err, "post")
+	}
+	return p, nil
+}
+
+// refresh reloads a stale entry in the background, serving the stale one
+// meanwhile. Only one refresh per key runs at a time, and none once
+// shutdown has begun.
+func (s *PostService) refresh(ctx context.Context, key string, id int) {
+	if _, busy := s.refreshing.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+	link := trace.LinkFromContext(ctx)
+	started := s.lc.Go(func(ctx context.Context) {
+		defer s.refreshing.Delete(key)
+		ctx = auth.WithPrincipal(ctx, auth.System)
+		ctx, span := tracing.Tracer().Start(ctx, "post.refresh_cache", trace.WithLinks(link))
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+		_, err := s.loadPost(ctx, key, id, false)
+		tracing.End(span, err)
+	})
+	if !started {
+		s.refreshing.Delete(key)
+	}
+}
+
+// readPost looks key up in the cache. An after-write marker is returned
+// but is not a hit.
+func (s *PostService) readPost(ctx context.Context, key string) (*cachedPost, bool) {
+	v, err := s.cache.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrMiss) {
+			metrics.PostCacheRequests.WithLabelValues("error").Inc()
+			logger.FromContext(ctx, s.log).Warn("cache read failed", zap.String("key", key), zap.Error(err))
+		} else {
+			metrics.PostCacheRequests.WithLabelValues("miss").Inc()
+		}
+		return nil, false
+	}
+	var e cachedPost
+	if json.Unmarshal(v, &e) != nil {
+		metrics.PostCacheRequests.WithLabelValues("error").Inc()
+		return nil, false
+	}
+	if e.AfterWrite {
+		metrics.PostCacheRequests.WithLabelValues("miss").Inc()
+		return &e, false
+	}
+	return &e, true
+}
+
+// loadPost reads the post from a replica, or the primary if asked, and
+// backfills the cache unless the post was written meanwhile. Concurrent
+// loads of the same key share one query.
+func (s *PostService) loadPost(ctx context.Context, key string, id int, primary bool) (*models.Post, error) {
+	flight := key
+	if primary {
+		// must not share a replica read that started before the write
+		flight += ":primary"
+	}
+	v, err, _ := s.loads.Do(flight, func() (any, error) {
+		// a cancelled first caller must not fail everyone waiting on it
+		ctx := context.WithoutCancel(ctx)
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+
+		gen, genOK := s.generation(ctx, id)
+		var p *models.Post
+		read := func(db *gorm.DB) (err error) {
+			p, err = s.repo.GetByID(ctx, db, id)
+			return err
+		}
+		var err error
+		if primary {
+			err = read(s.db)
+		} else {
+			err = s.fromReplica(ctx, read)
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if ttl := cache.NegativeTTL(s.cfg); ttl > 0 && genOK {
+				s.backfill(ctx, id, gen, nil, ttl)
+			}
+			return nil, err
+		case err != nil:
+			return nil, err
+		}
+		if genOK {
+			s.backfill(ctx, id, gen, p, cache.Jitter(cache.TTL(s.cfg), s.cfg.Cache.TTLJitter))
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// hand each caller its own copy
+	p := *v.(*models.Post)
+	return &p, nil
+}
+
+// generation reads the write token of post id, "" if there is none. ok is
+// false when the cache cannot tell, and then nothing may be backfilled.
+func (s *PostService) generation(ctx context.Context, id int) (string, bool) {
+	v, err := s.cache.Get(ctx, genKey(id))
+	switch {
+	case errors.Is(err, cache.ErrMiss):
+		return "", true
+	case err != nil:
+		return "", false
+	}
+	return string(v), true
+}
+
+// backfill caches p, read while the write token was gen. A write that
+// lands before the entry is stored skips it; one that lands just after is
+// caught by the second check, which invalidates the entry again.
+func (s *PostService) backfill(ctx context.Context, id int, gen string, p *models.Post, ttl time.Duration) {
+	if cur, ok := s.generation(ctx, id); !ok || cur != gen {
+		return
+	}
+	s.writePost(ctx, postKey(id), p, ttl)
+	if cur, ok := s.generation(ctx, id); !ok || cur != gen {
+		s.invalidate(ctx, id)
+	}
+}
+
+func (s *PostService) writePost(ctx context.Context, key string, p *models.Post, ttl time.Duration) {
+	b, err := json.Marshal(cachedPost{Post: p, FreshUntil: time.Now().Add(ttl)})
+	if err != nil {
+		return
+	}
+	if err := s.cache.Set(ctx, key, b, ttl+cache.StaleWindow(s.cfg)); err != nil {
+		logger.FromContext(ctx, s.log).Warn("cache write failed", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// fromReplica runs read against the next replica, or the primary when
+// there are none or the replica fails. Not found is an answer, not a
+// failure, so it is not retried.
+func (s *PostService) fromReplica(ctx context.Context, read func(db *gorm.DB) error) error {
+	if db := s.replicas.Next(); db != nil {
+		err := read(db)
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+			return err
+		}
+		logger.FromContext(ctx, s.log).Warn("replica read failed, using primary", zap.Error(err))
+	}
+	return read(s.db)
+}
+
+// wrote invalidates the cached post id after a write. It first replaces
+// the write token, so loads already under way do not cache the old row.
+// The writer's tag listings stay on the primary for db.read_after_write.
+func (s *PostService) wrote(ctx context.Context, id int) {
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := s.cache.Set(ctx, genKey(id), []byte(gen), cache.TTL(s.cfg)+cache.StaleWindow(s.cfg)); err != nil {
+		logger.FromContext(ctx, s.log).Warn("cache write failed", zap.String("key", genKey(id)), zap.Error(err))
+	}
+	s.invalidate(ctx, id)
+	if w := s.cfg.DB.ReadAfterWrite; s.replicas.Len() > 0 && w > 0 {
+		if userID, ok := auth.UserID(ctx); ok {
+			_ = s.cache.Set(ctx, writerKey(userID), []byte{1}, w)
+		}
+	}
+}
+
+// invalidate drops the cached post id. With replicas, it instead leaves an
+// after-write marker for db.read_after_write, so reads of the post go to
+// the primary until replicas have caught up rather than caching what a
+// lagging replica returns.
+func (s *PostService) invalidate(ctx context.Context, id int) {
+	w := s.cfg.DB.ReadAfterWrite
+	if s.replicas.Len() == 0 || w <= 0 {
+		_ = s.cache.Del(ctx, postKey(id))
+		return
+	}
+	b, _ := json.Marshal(cachedPost{AfterWrite: true})
+	if err := s.cache.Set(ctx, postKey(id), b, w); err != nil {
+		logger.FromContext(ctx, s.log).Warn("cache write failed", zap.String("key", postKey(id)), zap.Error(err))
+		_ = s.cache.Del(ctx, postKey(id))
+	}
+}
+
+// recentWriter reports whether the caller wrote a post within
+// db.read_after_write.
+func (s *PostService) recentWriter(ctx context.Context) bool {
+	userID, ok := auth.UserID(ctx)
+	if !ok || s.replicas.Len() == 0 {
+		return false
+	}
+	_, err := s.cache.Get(ctx, writerKey(userID))
+	return err == nil
+}
@@ -2,36 +2,54 @@ package service
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/xuanviet96/seta-training/internal/auth"
 	"github.com/xuanviet96/seta-training/internal/cache"
 	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/database"
 	"github.com/xuanviet96/seta-training/internal/domain/models"
 	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	"github.com/xuanviet96/seta-training/internal/lifecycle"
 	search "github.com/xuanviet96/seta-training/internal/search"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 type PostService struct {
-	cfg   config.Config
-	log   *zap.Logger
-	db    *gorm.DB
-	cache *redis.Client
-	repo  repository.PostRepository
-	es    *search.ESClient
+	cfg       config.Config
+	log       *zap.Logger
+	db        *gorm.DB
+	replicas  *database.Replicas
+	cache     cache.Cache
+	repo      repository.PostRepository
+	outbox    repository.OutboxRepository
+	revisions repository.RevisionRepository
+	searcher  search.Backend
+	policy    auth.Policy
+	lc        *lifecycle.Lifecycle
+	loads     singleflight.Group
+	// refreshing holds the keys with a stale-while-revalidate refresh
+	// under way
+	refreshing sync.Map
 }
 
-func NewPostService(cfg config.Config, log *zap.Logger, db *gorm.DB, cache *redis.Client, repo repository.PostRepository, es *search.ESClient) *PostService {
-	return &PostService{cfg: cfg, log: log, db: db, cache: cache, repo: repo, es: es}
+func NewPostService(cfg config.Config, log *zap.Logger, db *gorm.DB, replicas *database.Replicas, cache cache.Cache, repo repository.PostRepository, outbox repository.OutboxRepository, revisions repository.RevisionRepository, searcher search.Backend, policy auth.Policy, lc *lifecycle.Lifecycle) *PostService {
+	return &PostService{cfg: cfg, log: log, db: db, replicas: replicas, cache: cache, repo: repo, outbox: outbox, revisions: revisions, searcher: searcher, policy: policy, lc: lc}
 }
 
 func (s *PostService) Create(ctx context.Context, p *models.Post) (*models.Post, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostCreate); err != nil {
+		return nil, err
+	}
+	if userID, ok := auth.UserID(ctx); ok {
+		p.AuthorID = &userID
+	}
 	var out *models.Post
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// create post + activity log in same transaction
@@ -42,79 +60,227 @@ func (s *PostService) Create(ctx context.Context, p *models.Post) (*models.Post,
 		if err := s.repo.CreateWithLog(ctx, tx, p, al); err != nil {
 			return err
 		}
+		if err := s.revisions.Append(ctx, tx, revisionOf(ctx, p, models.RevisionCreate, nil)); err != nil {
+			return err
+		}
 		out = p
-		return nil
+		// index to ES via the outbox
+		return s.enqueue(ctx, tx, models.OutboxOpIndex, p.ID)
 	})
 	if err != nil {
 		return nil, err
 	}
+	// drop a negative entry left by an earlier lookup of this id
+	s.wrote(ctx, out.ID)
+	return out, nil
+}
 
-	// index to ES (best-effort)
-	go func(p models.Post) {
-		ctx2, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
-		defer cancel()
-		_ = search.IndexPost(ctx2, s.es, s.cfg.ESIndex, search.PostDoc{
-			ID:      p.ID,
-			Title:   p.Title,
-			Content: p.Content,
-			Tags:    []string(p.Tags), // <-- cast
-		})
-	}(*out)
+// PostPatch is a partial update; nil fields are left as they are.
+type PostPatch struct {
+	Title   *string
+	Content *string
+	Tags    *[]string
+}
 
-	return out, nil
+// Update applies patch to the post as it stands in the primary, locked for
+// the transaction. check, when set, sees that post first and may refuse the
+// update, e.g. because the client's If-Match no longer holds.
+func (s *PostService) Update(ctx context.Context, id int, patch PostPatch, check func(cur *models.Post) error) (*models.Post, error) {
+	return s.update(ctx, id, models.RevisionUpdate, nil, func(p *models.Post) error {
+		if check != nil {
+			if err := check(p); err != nil {
+				return err
+			}
+		}
+		if patch.Title != nil {
+			p.Title = strings.TrimSpace(*patch.Title)
+		}
+		if patch.Content != nil {
+			p.Content = strings.TrimSpace(*patch.Content)
+		}
+		if patch.Tags != nil {
+			p.Tags = pq.StringArray(*patch.Tags)
+		}
+		return nil
+	})
 }
 
-func (s *PostService) GetByID(ctx context.Context, id int) (*models.Post, error) {
-	key := fmt.Sprintf("post:%d", id)
+// update locks post id, lets apply change it, writes it and records it as
+// a new revision in one transaction. Reading the version under the row
+// lock, not through the cache or a replica, means the write cannot lose
+// to a concurrent one.
+func (s *PostService) update(ctx context.Context, id int, action string, rollbackOf *int, apply func(p *models.Post) error) (*models.Post, error) {
+	var p *models.Post
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.authorize(ctx, tx, id, auth.PermPostUpdateAny, auth.PermPostUpdateOwn); err != nil {
+			return err
+		}
+		var err error
+		if p, err = s.repo.GetForUpdate(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := apply(p); err != nil {
+			return err
+		}
+		al := &models.ActivityLog{
+			Action:   "update_post",
+			LoggedAt: time.Now(),
+		}
+		if err := s.repo.UpdateWithLog(ctx, tx, p, al); err != nil {
+			return err
+		}
+		if err := s.revisions.Append(ctx, tx, revisionOf(ctx, p, action, rollbackOf)); err != nil {
+			return err
+		}
+		// re-index
+		return s.enqueue(ctx, tx, models.OutboxOpIndex, p.ID)
+	})
+	if err != nil {
+		return nil, notFound(err, "post")
+	}
+	// invalidate cache
+	s.wrote(ctx, p.ID)
+
+	return p, nil
+}
 
-	// cache read
-	if v, err := s.cache.Get(ctx, key).Result(); err == nil && v != "" {
-		var p models.Post
-		if json.Unmarshal([]byte(v), &p) == nil {
-			return &p, nil
+func (s *PostService) Delete(ctx context.Context, id int) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.authorize(ctx, tx, id, auth.PermPostDeleteAny, auth.PermPostDeleteOwn); err != nil {
+			return err
 		}
+		al := &models.ActivityLog{
+			Action:   "delete_post",
+			LoggedAt: time.Now(),
+		}
+		if err := s.repo.DeleteWithLog(ctx, tx, id, al); err != nil {
+			return err
+		}
+		return s.enqueue(ctx, tx, models.OutboxOpDelete, id)
+	})
+	if err != nil {
+		return notFound(err, "post")
 	}
+	s.wrote(ctx, id)
+	return nil
+}
 
-	// miss → db
+func (s *PostService) Restore(ctx context.Context, id int) (*models.Post, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.authorize(ctx, tx, id, auth.PermPostRestoreAny, auth.PermPostRestoreOwn); err != nil {
+			return err
+		}
+		al := &models.ActivityLog{
+			Action:   "restore_post",
+			LoggedAt: time.Now(),
+		}
+		if err := s.repo.RestoreWithLog(ctx, tx, id, al); err != nil {
+			return err
+		}
+		return s.enqueue(ctx, tx, models.OutboxOpIndex, id)
+	})
+	if err != nil {
+		return nil, notFound(err, "deleted post")
+	}
+	s.wrote(ctx, id)
 	p, err := s.repo.GetByID(ctx, s.db, id)
 	if err != nil {
-		return nil, err
+		// purged or deleted again since the restore committed
+		return nil, notFound(err, "post")
 	}
+	return p, nil
+}
 
-	// backfill cache
-	if b, err := json.Marshal(p); err == nil {
-		_ = s.cache.Set(ctx, key, b, cache.TTL(s.cfg)).Err()
+// Purge hard-deletes a post.
+func (s *PostService) Purge(ctx context.Context, id int) error {
+	if err := auth.Require(ctx, s.policy, auth.PermPostPurge); err != nil {
+		return err
 	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.Purge(ctx, tx, id); err != nil {
+			return err
+		}
+		return s.enqueue(ctx, tx, models.OutboxOpDelete, id)
+	})
+	if err != nil {
+		return notFound(err, "post")
+	}
+	s.wrote(ctx, id)
+	return nil
+}
 
-	return p, nil
+// authorize checks that the caller may act on post id: anyPerm covers
+// every post, ownPerm only the caller's own.
+func (s *PostService) authorize(ctx context.Context, db *gorm.DB, id int, anyPerm, ownPerm string) error {
+	if auth.Holds(ctx, s.policy, anyPerm) {
+		return nil
+	}
+	caller := auth.FromContext(ctx)
+	if caller == nil || !auth.Holds(ctx, s.policy, ownPerm) {
+		return &auth.PermissionError{Missing: []string{ownPerm, anyPerm}}
+	}
+	author, err := s.repo.AuthorOf(ctx, db, id)
+	if err != nil {
+		return err
+	}
+	if author == nil || *author != caller.UserID {
+		return &auth.PermissionError{Missing: []string{anyPerm}}
+	}
+	return nil
 }
 
-func (s *PostService) Update(ctx context.Context, p *models.Post) (*models.Post, error) {
-	if err := s.repo.Update(ctx, s.db, p); err != nil {
+// enqueue records a search index operation in the outbox so it commits or
+// rolls back together with the post change. Without Elasticsearch nothing
+// would deliver the event, so none is written.
+func (s *PostService) enqueue(ctx context.Context, tx *gorm.DB, op string, postID int) error {
+	if s.cfg.ES.Addr == "" {
+		return nil
+	}
+	return s.outbox.Enqueue(ctx, tx, &models.OutboxEvent{Op: op, PostID: postID})
+}
+
+func (s *PostService) List(ctx context.Context, f repository.PostFilter, page repository.PageRequest) (*repository.PostPage, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostRead); err != nil {
 		return nil, err
 	}
-	// invalidate cache
-	_ = s.cache.Del(ctx, "post:"+strconv.Itoa(p.ID)).Err()
-
-	// re-index
-	go func(p models.Post) {
-		ctx2, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
-		defer cancel()
-		_ = search.IndexPost(ctx2, s.es, s.cfg.ESIndex, search.PostDoc{
-			ID:      p.ID,
-			Title:   p.Title,
-			Content: p.Content,
-			Tags:    []string(p.Tags), // <-- cast
-		})
-	}(*p)
+	return s.repo.List(ctx, s.db, f, page)
+}
 
-	return p, nil
+func (s *PostService) SearchByTag(ctx context.Context, tag string, page repository.PageRequest) (*repository.PostPage, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostRead); err != nil {
+		return nil, err
+	}
+	// the caller's own recent writes may not have reached a replica yet
+	if s.recentWriter(ctx) {
+		return s.repo.SearchByTag(ctx, s.db, tag, page)
+	}
+	var out *repository.PostPage
+	err := s.fromReplica(ctx, func(db *gorm.DB) (err error) {
+		out, err = s.repo.SearchByTag(ctx, db, tag, page)
+		return err
+	})
+	return out, err
 }
 
-func (s *PostService) SearchByTag(ctx context.Context, tag string) ([]models.Post, error) {
-	return s.repo.SearchByTag(ctx, s.db, tag)
+func (s *PostService) Search(ctx context.Context, q string, opts search.SearchOptions) (*search.SearchResult, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostRead); err != nil {
+		return nil, err
+	}
+	if opts.TitleBoost == 0 {
+		opts.TitleBoost = s.cfg.ES.TitleBoost
+	}
+	if opts.ContentBoost == 0 {
+		opts.ContentBoost = s.cfg.ES.ContentBoost
+	}
+	return s.searcher.Search(ctx, q, opts)
 }
 
-func (s *PostService) SearchES(ctx context.Context, q string) ([]search.PostDoc, int, error) {
-	return search.SearchPosts(ctx, s.es, s.cfg.ESIndex, q)
+func toDoc(p models.Post) search.PostDoc {
+	return search.PostDoc{
+		ID:        p.ID,
+		Title:     p.Title,
+		Content:   p.Content,
+		Tags:      []string(p.Tags),
+		CreatedAt: p.CreatedAt,
+	}
 }
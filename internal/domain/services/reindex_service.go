@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/database"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	search "github.com/xuanviet96/seta-training/internal/search"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var ErrReindexRunning = Conflict("reindex already running")
+
+// catchUpSkew widens each catch-up window so events stamped by a slightly
+// lagging clock are not missed. Re-applying an event is harmless.
+const catchUpSkew = 5 * time.Second
+
+type ReindexOptions struct {
+	BatchSize int
+	DeleteOld bool
+}
+
+type ReindexReport struct {
+	Alias      string   `json:"alias"`
+	NewIndex   string   `json:"new_index"`
+	OldIndices []string `json:"old_indices"`
+	Indexed    int      `json:"indexed"`
+	CaughtUp   int      `json:"caught_up"`
+	Failed     int      `json:"failed"`
+	Deleted    []string `json:"deleted,omitempty"`
+	Took       string   `json:"took"`
+}
+
+// Reindexer rebuilds the posts index from Postgres into a fresh versioned
+// index and swaps the alias over once it is complete.
+type Reindexer struct {
+	cfg    config.Config
+	log    *zap.Logger
+	db     *gorm.DB
+	posts  repository.PostRepository
+	outbox repository.OutboxRepository
+	es     *search.ESClient
+}
+
+func NewReindexer(cfg config.Config, log *zap.Logger, db *gorm.DB, posts repository.PostRepository, outbox repository.OutboxRepository, es *search.ESClient) *Reindexer {
+	return &Reindexer{cfg: cfg, log: log, db: db, posts: posts, outbox: outbox, es: es}
+}
+
+// Run copies every live post into a new index. Writes that land while the
+// copy is running keep going to the old index through the alias; they are
+// replayed into the new index from the outbox before and after the swap.
+// A Postgres advisory lock keeps a second run, from this or any other
+// process, from starting meanwhile.
+//
+// A legacy concrete index named like the alias has to go for the alias to
+// take its name, so it is only migrated when DeleteOld is set.
+func (r *Reindexer) Run(ctx context.Context, opts ReindexOptions) (*ReindexReport, error) {
+	unlock, ok, err := database.TryLock(ctx, r.db, database.ReindexLockID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReindexRunning
+	}
+	defer unlock()
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	started := time.Now()
+	alias := r.cfg.ES.Index
+	rep := &ReindexReport{Alias: alias}
+
+	old, err := search.AliasIndices(ctx, r.es, alias)
+	if err != nil {
+		return nil, err
+	}
+	if len(old) == 0 {
+		legacy, err := search.IndexExists(ctx, r.es, alias)
+		if err != nil {
+			return nil, err
+		}
+		if legacy && !opts.DeleteOld {
+			return nil, Conflict("index %q is not behind an alias; migrating it deletes it, rerun with delete_old", alias)
+		}
+		if legacy {
+			old = []string{alias}
+		}
+	}
+	rep.OldIndices = old
+	rep.NewIndex = search.NextIndex(alias, old)
+
+	if err := search.CreateIndex(ctx, r.es, rep.NewIndex); err != nil {
+		return nil, err
+	}
+	log := r.log.With(zap.String("alias", alias), zap.String("index", rep.NewIndex))
+	log.Info("reindex started", zap.Strings("old", old))
+
+	for after := 0; ; {
+		batch, err := r.posts.Batch(ctx, r.db, after, opts.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		docs := make([]search.PostDoc, 0, len(batch))
+		for _, p := range batch {
+			docs = append(docs, toDoc(p))
+		}
+		failed, err := search.BulkIndex(ctx, r.es, rep.NewIndex, docs)
+		if err != nil {
+			return nil, err
+		}
+		for id, reason := range failed {
+			log.Warn("reindex document rejected", zap.Int("post_id", id), zap.String("reason", reason))
+		}
+		rep.Indexed += len(docs) - len(failed)
+		rep.Failed += len(failed)
+		after = batch[len(batch)-1].ID
+	}
+
+	mark, n, err := r.catchUp(ctx, rep.NewIndex, started)
+	if err != nil {
+		return nil, err
+	}
+	rep.CaughtUp += n
+
+	if err := search.SwapAlias(ctx, r.es, alias, rep.NewIndex, old); err != nil {
+		return nil, err
+	}
+	log.Info("reindex alias swapped")
+
+	// events written between the first catch-up and the swap went to the
+	// old index only
+	if _, n, err = r.catchUp(ctx, rep.NewIndex, mark); err != nil {
+		return nil, err
+	}
+	rep.CaughtUp += n
+
+	if opts.DeleteOld {
+		for _, o := range old {
+			if o == rep.NewIndex {
+				continue
+			}
+			// a legacy index was already dropped by the swap
+			if o == alias {
+				rep.Deleted = append(rep.Deleted, o)
+				continue
+			}
+			if err := search.DeleteIndex(ctx, r.es, o); err != nil {
+				log.Warn("reindex could not delete old index", zap.String("old", o), zap.Error(err))
+				continue
+			}
+			rep.Deleted = append(rep.Deleted, o)
+		}
+	}
+
+	rep.Took = time.Since(started).String()
+	log.Info("reindex finished", zap.Int("indexed", rep.Indexed), zap.Int("caught_up", rep.CaughtUp), zap.Int("failed", rep.Failed))
+	return rep, nil
+}
+
+// catchUp re-applies every post touched in the outbox since the given time
+// to index and returns the mark to resume from next time.
+func (r *Reindexer) catchUp(ctx context.Context, index string, since time.Time) (time.Time, int, error) {
+	mark := time.Now()
+	ids, err := r.outbox.PostIDsSince(ctx, r.db, since.Add(-catchUpSkew))
+	if err != nil {
+		return since, 0, err
+	}
+	for _, id := range ids {
+		p, err := r.posts.GetByID(ctx, r.db, id)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = search.DeletePost(ctx, r.es, index, id)
+		case err == nil:
+			err = search.IndexPost(ctx, r.es, index, toDoc(*p))
+		}
+		if err != nil {
+			return since, 0, err
+		}
+	}
+	return mark, len(ids), nil
+}
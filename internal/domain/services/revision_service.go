@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/pkg/textdiff"
+
+	"github.com/lib/pq"
+)
+
+// diffContext is the number of unchanged lines shown around content edits.
+const diffContext = 3
+
+type StringChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type TagsChange struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// RevisionDiff compares two revisions field by field. Unchanged fields are
+// left empty.
+type RevisionDiff struct {
+	PostID  int           `json:"post_id"`
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Title   *StringChange `json:"title,omitempty"`
+	Tags    *TagsChange   `json:"tags,omitempty"`
+	Content string        `json:"content,omitempty"`
+}
+
+func revisionOf(ctx context.Context, p *models.Post, action string, rollbackOf *int) *models.PostRevision {
+	rev := &models.PostRevision{
+		PostID:     p.ID,
+		Title:      p.Title,
+		Content:    p.Content,
+		Tags:       append(pq.StringArray{}, p.Tags...),
+		Action:     action,
+		RollbackOf: rollbackOf,
+	}
+	if userID, ok := auth.UserID(ctx); ok {
+		rev.EditorID = &userID
+	}
+	return rev
+}
+
+// ListRevisions returns a post's history, newest first.
+func (s *PostService) ListRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostRead); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetByID(ctx, s.db, postID); err != nil {
+		return nil, notFound(err, "post")
+	}
+	return s.revisions.List(ctx, s.db, postID)
+}
+
+func (s *PostService) GetRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	if err := auth.Require(ctx, s.policy, auth.PermPostRead); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetByID(ctx, s.db, postID); err != nil {
+		return nil, notFound(err, "post")
+	}
+	rev, err := s.revisions.Get(ctx, s.db, postID, revision)
+	if err != nil {
+		return nil, notFound(err, "revision")
+	}
+	return rev, nil
+}
+
+func (s *PostService) DiffRevisions(ctx context.Context, postID, from, to int) (*RevisionDiff, error) {
+	a, err := s.GetRevision(ctx, postID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.revisions.Get(ctx, s.db, postID, to)
+	if err != nil {
+		return nil, notFound(err, "revision")
+	}
+
+	d := &RevisionDiff{PostID: postID, From: from, To: to}
+	if a.Title != b.Title {
+		d.Title = &StringChange{From: a.Title, To: b.Title}
+	}
+	if added, removed := tagDelta(a.Tags, b.Tags); len(added)+len(removed) > 0 {
+		d.Tags = &TagsChange{Added: added, Removed: removed}
+	}
+	d.Content, err = textdiff.Unified(
+		fmt.Sprintf("revision %d", from), fmt.Sprintf("revision %d", to),
+		a.Content, b.Content, diffContext)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Rollback restores the fields of an earlier revision. History is never
+// rewritten: the rollback is recorded as a new revision.
+func (s *PostService) Rollback(ctx context.Context, postID, revision int) (*models.Post, error) {
+	rev, err := s.GetRevision(ctx, postID, revision)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(ctx, postID, models.RevisionRollback, &rev.Revision, func(p *models.Post) error {
+		p.Title = rev.Title
+		p.Content = rev.Content
+		p.Tags = append(pq.StringArray{}, rev.Tags...)
+		return nil
+	})
+}
+
+func tagDelta(from, to []string) (added, removed []string) {
+	in := func(set []string, t string) bool {
+		for _, x := range set {
+			if x == t {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range to {
+		if !in(from, t) {
+			added = append(added, t)
+		}
+	}
+	for _, t := range from {
+		if !in(to, t) {
+			removed = append(removed, t)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,133 @@
+// Package health runs dependency checks for the readiness probe. Checks
+// run in parallel and their results are cached briefly so frequent probes
+// do not hammer the dependencies.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDown     = "down"
+	StatusDisabled = "disabled"
+)
+
+// Check probes one dependency. A nil Probe reports the dependency as
+// disabled, which never fails readiness.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Result is the outcome of one check. LastError and LastSuccess carry over
+// between runs so a flapping dependency is visible.
+type Result struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	LatencyMS   float64    `json:"latency_ms"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+type Report struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Ready reports whether every critical dependency is up.
+func (r *Report) Ready() bool { return r.Status == StatusOK }
+
+type Checker struct {
+	checks   []Check
+	critical map[string]bool
+	timeout  time.Duration
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	last   map[string]Result
+	report *Report
+}
+
+// Options tunes a Checker. Checks named in Critical fail readiness when
+// down; the rest are reported but optional.
+type Options struct {
+	Critical []string
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+func NewChecker(checks []Check, opts Options) *Checker {
+	crit := make(map[string]bool, len(opts.Critical))
+	for _, name := range opts.Critical {
+		crit[name] = true
+	}
+	return &Checker{checks: checks, critical: crit, timeout: opts.Timeout, ttl: opts.CacheTTL, last: map[string]Result{}}
+}
+
+// Run returns the cached report if it is younger than the TTL, otherwise
+// probes every dependency. Concurrent callers share one run. Probes get
+// their own timeout, detached from ctx, so a probe client that hangs up
+// early cannot record its dependencies as down.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.report != nil && time.Since(c.report.CheckedAt) < c.ttl {
+		return *c.report
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func(i int, chk Check) {
+			defer wg.Done()
+			results[i] = c.probe(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, CheckedAt: time.Now(), Checks: results}
+	for i, r := range results {
+		prev := c.last[r.Name]
+		r.LastSuccess = prev.LastSuccess
+		if r.Status == StatusOK {
+			now := report.CheckedAt
+			r.LastSuccess = &now
+		}
+		if r.LastError == "" {
+			r.LastError = prev.LastError
+		}
+		if r.Status == StatusDown && r.Critical {
+			report.Status = StatusDown
+		}
+		c.last[r.Name] = r
+		results[i] = r
+	}
+	c.report = report
+	return *report
+}
+
+func (c *Checker) probe(ctx context.Context, chk Check) Result {
+	r := Result{Name: chk.Name, Status: StatusDisabled, Critical: c.critical[chk.Name]}
+	if chk.Probe == nil {
+		return r
+	}
+	start := time.Now()
+	err := chk.Probe(ctx)
+	r.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		r.Status = StatusDown
+		r.LastError = err.Error()
+		return r
+	}
+	r.Status = StatusOK
+	return r
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type AuthHandler struct {
+	svc *service.AuthService
+	val *validator.Validate
+}
+
+func NewAuthHandler(svc *service.AuthService) *AuthHandler {
+	return &AuthHandler{svc: svc, val: newValidator()}
+}
+
+type credentialsReq struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req credentialsReq
+	if !bindJSON(c, h.val, &req) {
+		return
+	}
+	u, tokens, err := h.svc.Register(c, req.Email, req.Password)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"user": u, "tokens": tokens})
+}
+
+type loginReq struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req loginReq
+	if !bindJSON(c, h.val, &req) {
+		return
+	}
+	tokens, err := h.svc.Login(c, req.Email, req.Password)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req refreshReq
+	if !bindJSON(c, h.val, &req) {
+		return
+	}
+	tokens, err := h.svc.Refresh(c, req.RefreshToken)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+)
+
+// postETag is a strong validator built from the post's version, which
+// changes on every update.
+func postETag(p *models.Post) string {
+	return `"` + strconv.Itoa(p.Version) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists
+// etag. If-Match uses strong comparison (RFC 9110 13.1.1), so weak tags
+// never match it; If-None-Match compares weakly.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
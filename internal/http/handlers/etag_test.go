@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+)
+
+func TestPostETag(t *testing.T) {
+	tests := []struct {
+		version int
+		want    string
+	}{
+		{version: 1, want: `"1"`},
+		{version: 42, want: `"42"`},
+	}
+	for _, tt := range tests {
+		if got := postETag(&models.Post{Version: tt.version}); got != tt.want {
+			t.Errorf("postETag(version %d) = %s, want %s", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"3"`
+	tests := []struct {
+		name   string
+		header string
+		weak   bool
+		want   bool
+	}{
+		{name: "exact", header: `"3"`, want: true},
+		{name: "other version", header: `"2"`, want: false},
+		{name: "unquoted", header: `3`, want: false},
+		{name: "empty", header: ``, want: false},
+		{name: "wildcard", header: `*`, want: true},
+		{name: "list", header: `"1", "3"`, want: true},
+		{name: "list without spaces", header: `"1","2","3"`, want: true},
+		{name: "list miss", header: `"1", "2"`, want: false},
+		{name: "wildcard in list", header: `"1", *`, want: true},
+		{name: "weak strong comparison", header: `W/"3"`, want: false},
+		{name: "weak weak comparison", header: `W/"3"`, weak: true, want: true},
+		{name: "weak in list strong", header: `W/"3", "4"`, want: false},
+		{name: "weak in list weak", header: `"4", W/"3"`, weak: true, want: true},
+		{name: "strong weak comparison", header: `"3"`, weak: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.header, etag, tt.weak); got != tt.want {
+				t.Errorf("etagMatches(%q, %s, weak=%v) = %v, want %v", tt.header, etag, tt.weak, got, tt.want)
+			}
+		})
+	}
+}
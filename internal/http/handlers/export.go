@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	ex *service.Exporter
+}
+
+func NewExportHandler(ex *service.Exporter) *ExportHandler {
+	return &ExportHandler{ex: ex}
+}
+
+// Export streams matching posts as an attachment. It takes the same
+// filters as List and ?format=ndjson|csv|markdown.
+func (h *ExportHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", service.ExportNDJSON)
+	ct, ok := service.ExportContentTypes[format]
+	if !ok {
+		invalidParam(c, "format", "must be ndjson, csv or markdown")
+		return
+	}
+	f, ok := bindPostFilter(c)
+	if !ok {
+		return
+	}
+
+	name := "posts-" + time.Now().UTC().Format("20060102-150405") + ct[1]
+	c.Header("Content-Type", ct[0])
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	if _, err := h.ex.Export(c, c.Writer, format, f); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			middleware.Fail(c, err)
+			return
+		}
+		// too late for an error response; cut the stream short
+		_ = c.Error(err)
+		c.Abort()
+		return
+	}
+	if !c.Writer.Written() {
+		// an empty NDJSON or CSV export still answers 200
+		c.Status(http.StatusOK)
+	}
+}
@@ -3,51 +3,65 @@ package handlers
 import (
 	"context"
 	"net/http"
-	"time"
 
+	"github.com/xuanviet96/seta-training/internal/cache"
+	"github.com/xuanviet96/seta-training/internal/health"
+	"github.com/xuanviet96/seta-training/internal/lifecycle"
 	httpserversearch "github.com/xuanviet96/seta-training/internal/search"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type HealthHandler struct {
-	DB *gorm.DB
-	RD *redis.Client
-	ES *httpserversearch.ESClient
+	checker *health.Checker
+	lc      *lifecycle.Lifecycle
 }
 
-func NewHealthHandler(db *gorm.DB, rd *redis.Client, es *httpserversearch.ESClient) *HealthHandler {
-	return &HealthHandler{DB: db, RD: rd, ES: es}
-}
-
-func (h *HealthHandler) Get(c *gin.Context) {
-	dbOK := "ok"
-	if sqlDB, err := h.DB.DB(); err != nil || sqlDB.Ping() != nil {
-		dbOK = "down"
+// NewHealthHandler checks the database, cache and Elasticsearch. A disabled
+// cache or unconfigured ES client is reported as disabled rather than
+// down; an ES that is unreachable, even since startup, is down until it
+// answers.
+func NewHealthHandler(db *gorm.DB, c cache.Cache, es *httpserversearch.ESClient, lc *lifecycle.Lifecycle, opts health.Options) *HealthHandler {
+	checks := []health.Check{{
+		Name: "db",
+		Probe: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}}
+	cacheCheck := health.Check{Name: "cache"}
+	if c.Name() != cache.DriverNone {
+		cacheCheck.Probe = c.Ping
 	}
+	esCheck := health.Check{Name: "es"}
+	if es != nil {
+		esCheck.Probe = es.Ping
+	}
+	checks = append(checks, cacheCheck, esCheck)
+	return &HealthHandler{checker: health.NewChecker(checks, opts), lc: lc}
+}
 
-	// Derive a request-scoped context with timeout for external checks
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
-	defer cancel()
+// Livez reports that the process is up and serving; it never checks
+// dependencies so a database outage does not get the pod restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
 
-	redisOK := "ok"
-	if err := h.RD.Ping(ctx).Err(); err != nil {
-		redisOK = "down"
+// Readyz reports whether the critical dependencies are reachable, with
+// per-check details. It fails as soon as shutdown begins.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	if h.lc.ShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
 	}
-
-	esOK := "ok"
-	if resp, err := h.ES.Client.Info(h.ES.Client.Info.WithContext(ctx)); err != nil {
-		esOK = "down"
-	} else {
-		_ = resp.Body.Close()
+	report := h.checker.Run(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-		"db":     dbOK,
-		"redis":  redisOK,
-		"es":     esOK,
-	})
+	c.JSON(status, report)
 }
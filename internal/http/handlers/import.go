@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes caps the body of one bulk import request.
+const maxImportBytes = 32 << 20
+
+type ImportHandler struct {
+	im *service.Importer
+}
+
+func NewImportHandler(im *service.Importer) *ImportHandler {
+	return &ImportHandler{im: im}
+}
+
+// Bulk creates posts from an NDJSON or CSV body. The format comes from
+// ?format= or the Content-Type. Like reindexing, the import runs to the
+// end even if the caller disconnects.
+func (h *ImportHandler) Bulk(c *gin.Context) {
+	opts := service.ImportOptions{
+		Format: c.Query("format"),
+		DryRun: c.Query("dry_run") == "true",
+	}
+	if opts.Format == "" {
+		opts.Format = service.ImportFormat(c.ContentType())
+	}
+	if opts.Format != service.ImportNDJSON && opts.Format != service.ImportCSV {
+		invalidParam(c, "format", "must be ndjson or csv, or implied by Content-Type")
+		return
+	}
+	if raw := c.Query("batch"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			invalidParam(c, "batch", "must be a positive integer")
+			return
+		}
+		opts.BatchSize = n
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	rep, err := h.im.Run(context.WithoutCancel(c.Request.Context()), body, opts)
+	if err != nil && rep == nil {
+		middleware.Fail(c, err)
+		return
+	}
+	// the body broke off partway: rows before it are imported, so the
+	// client gets the report rather than a bare error
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+			rep.Aborted = "import body exceeds " + strconv.Itoa(maxImportBytes>>20) + "MB after row " + strconv.Itoa(rep.Total) + ", use the import command"
+		}
+	}
+	c.JSON(status, rep)
+}
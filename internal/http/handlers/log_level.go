@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/xuanviet96/seta-training/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+type LogLevelHandler struct {
+	log   *zap.Logger
+	level zap.AtomicLevel
+	val   *validator.Validate
+}
+
+func NewLogLevelHandler(log *zap.Logger, level zap.AtomicLevel) *LogLevelHandler {
+	return &LogLevelHandler{log: log, level: level, val: newValidator()}
+}
+
+type logLevelBody struct {
+	Level string `json:"level" validate:"required"`
+}
+
+func (h *LogLevelHandler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, logLevelBody{Level: h.level.String()})
+}
+
+// Set changes the level of every logger in the process until the next
+// restart, which goes back to LOG_LEVEL.
+func (h *LogLevelHandler) Set(c *gin.Context) {
+	var body logLevelBody
+	if !bindJSON(c, h.val, &body) {
+		return
+	}
+	from := h.level.String()
+	if err := h.level.UnmarshalText([]byte(body.Level)); err != nil {
+		invalidParam(c, "level", "must be debug, info, warn, error, dpanic, panic or fatal")
+		return
+	}
+	logger.FromContext(c.Request.Context(), h.log).Warn("log level changed",
+		zap.String("from", from), zap.String("to", h.level.String()))
+	c.JSON(http.StatusOK, logLevelBody{Level: h.level.String()})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OutboxHandler struct {
+	d *service.OutboxDispatcher
+}
+
+func NewOutboxHandler(d *service.OutboxDispatcher) *OutboxHandler {
+	return &OutboxHandler{d: d}
+}
+
+func (h *OutboxHandler) Metrics(c *gin.Context) {
+	m, err := h.d.Metrics(c)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// Requeue gives a dead-lettered event another round of delivery attempts.
+func (h *OutboxHandler) Requeue(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.d.Requeue(c, id); err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// newValidator reports fields by their JSON names.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// bindJSON decodes and validates the request body into dst. On failure it
+// records the error and returns false.
+func bindJSON(c *gin.Context, val *validator.Validate, dst any) bool {
+	if err := c.ShouldBindJSON(dst); err != nil {
+		middleware.Fail(c, service.Validation("malformed JSON body: "+err.Error()))
+		return false
+	}
+	if err := val.Struct(dst); err != nil {
+		middleware.Fail(c, validationError(err))
+		return false
+	}
+	return true
+}
+
+// validationError turns validator failures into per-field invalid params.
+func validationError(err error) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+	params := make([]service.InvalidParam, 0, len(verrs))
+	for _, fe := range verrs {
+		params = append(params, service.InvalidParam{Name: fe.Field(), Reason: reason(fe)})
+	}
+	return service.Validation("request body failed validation", params...)
+}
+
+func reason(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return "must have length at least " + fe.Param()
+	case "max":
+		return "must have length at most " + fe.Param()
+	}
+	return "failed " + fe.Tag() + " check"
+}
+
+// invalidParam records a 400 for a bad path or query parameter.
+func invalidParam(c *gin.Context, name, why string) {
+	middleware.Fail(c, service.Validation("invalid "+name, service.InvalidParam{Name: name, Reason: why}))
+}
+
+// pathID reads a positive integer path parameter.
+func pathID(c *gin.Context, name string) (int, bool) {
+	n, err := strconv.Atoi(c.Param(name))
+	if err != nil || n <= 0 {
+		invalidParam(c, name, "must be a positive integer")
+		return 0, false
+	}
+	return n, true
+}
@@ -4,14 +4,17 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xuanviet96/seta-training/internal/domain/models"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
 	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+	"github.com/xuanviet96/seta-training/internal/search"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/lib/pq"
-	"gorm.io/gorm"
 )
 
 type PostHandler struct {
@@ -20,7 +23,7 @@ type PostHandler struct {
 }
 
 func NewPostHandler(svc *service.PostService) *PostHandler {
-	return &PostHandler{svc: svc, val: validator.New()}
+	return &PostHandler{svc: svc, val: newValidator()}
 }
 
 type createPostReq struct {
@@ -31,12 +34,7 @@ type createPostReq struct {
 
 func (h *PostHandler) Create(c *gin.Context) {
 	var req createPostReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": gin.H{"code": "BAD_REQUEST", "message": err.Error()}})
-		return
-	}
-	if err := h.val.Struct(req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": gin.H{"code": "UNPROCESSABLE_ENTITY", "message": err.Error()}})
+	if !bindJSON(c, h.val, &req) {
 		return
 	}
 
@@ -47,9 +45,10 @@ func (h *PostHandler) Create(c *gin.Context) {
 	}
 	out, err := h.svc.Create(c, p)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "INTERNAL", "message": err.Error()}})
+		middleware.Fail(c, err)
 		return
 	}
+	c.Header("ETag", postETag(out))
 	c.JSON(http.StatusCreated, out)
 }
 
@@ -60,90 +59,233 @@ type updatePostReq struct {
 }
 
 func (h *PostHandler) Update(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "BAD_REQUEST", "message": "invalid id"}})
+	id, ok := pathID(c, "id")
+	if !ok {
 		return
 	}
 	var req updatePostReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": gin.H{"code": "BAD_REQUEST", "message": err.Error()}})
+	if !bindJSON(c, h.val, &req) {
 		return
 	}
 
-	// Load current
-	p, err := h.svc.GetByID(c, id)
+	// If-Match is checked against the locked row, not a cached copy
+	ifMatch := c.GetHeader("If-Match")
+	var current string
+	check := func(cur *models.Post) error {
+		if ifMatch != "" && !etagMatches(ifMatch, postETag(cur), false) {
+			current = postETag(cur)
+			return service.PreconditionFailed("post has changed, reload and retry")
+		}
+		return nil
+	}
+	patch := service.PostPatch{Title: req.Title, Content: req.Content, Tags: req.Tags}
+	out, err := h.svc.Update(c, id, patch, check)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "NOT_FOUND", "message": "post not found"}})
-			return
+		if current != "" {
+			c.Header("ETag", current)
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "INTERNAL", "message": err.Error()}})
+		middleware.Fail(c, err)
 		return
 	}
+	c.Header("ETag", postETag(out))
+	c.JSON(http.StatusOK, out)
+}
 
-	if req.Title != nil {
-		p.Title = strings.TrimSpace(*req.Title)
+func (h *PostHandler) GetByID(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
 	}
-	if req.Content != nil {
-		p.Content = strings.TrimSpace(*req.Content)
+	p, err := h.svc.GetByID(c, id)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
 	}
-	if req.Tags != nil {
-		p.Tags = pq.StringArray(*req.Tags) // <-- cast
+	etag := postETag(p)
+	c.Header("ETag", etag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		c.Status(http.StatusNotModified)
+		return
 	}
+	c.JSON(http.StatusOK, p)
+}
 
-	out, err := h.svc.Update(c, p)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "INTERNAL", "message": err.Error()}})
+func (h *PostHandler) Delete(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, out)
+	if err := h.svc.Delete(c, id); err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
-func (h *PostHandler) GetByID(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "BAD_REQUEST", "message": "invalid id"}})
+func (h *PostHandler) Restore(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
 		return
 	}
-	p, err := h.svc.GetByID(c, id)
+	p, err := h.svc.Restore(c, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "NOT_FOUND", "message": "post not found"}})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "INTERNAL", "message": err.Error()}})
+		middleware.Fail(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, p)
 }
 
+func (h *PostHandler) Purge(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.svc.Purge(c, id); err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PostHandler) List(c *gin.Context) {
+	page, ok := bindPageRequest(c)
+	if !ok {
+		return
+	}
+	f, ok := bindPostFilter(c)
+	if !ok {
+		return
+	}
+
+	out, err := h.svc.List(c, f, page)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
 func (h *PostHandler) SearchByTag(c *gin.Context) {
 	tag := strings.TrimSpace(c.Query("tag"))
 	if tag == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "BAD_REQUEST", "message": "tag required"}})
+		invalidParam(c, "tag", "is required")
 		return
 	}
-	items, err := h.svc.SearchByTag(c, tag)
+	page, ok := bindPageRequest(c)
+	if !ok {
+		return
+	}
+	out, err := h.svc.SearchByTag(c, tag, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "INTERNAL", "message": err.Error()}})
+		middleware.Fail(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items, "total": len(items)})
+	c.JSON(http.StatusOK, out)
+}
+
+// bindPostFilter reads ?tag=&tag_mode=&created_from=&created_to=&title_prefix=
+// and records a 400 on bad input.
+func bindPostFilter(c *gin.Context) (repository.PostFilter, bool) {
+	var f repository.PostFilter
+	for _, raw := range c.QueryArray("tag") {
+		for _, t := range strings.Split(raw, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				f.Tags = append(f.Tags, t)
+			}
+		}
+	}
+	f.TagMode = c.DefaultQuery("tag_mode", repository.TagModeAny)
+	if f.TagMode != repository.TagModeAny && f.TagMode != repository.TagModeAll {
+		invalidParam(c, "tag_mode", "must be any or all")
+		return f, false
+	}
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"created_from", &f.CreatedFrom}, {"created_to", &f.CreatedTo}} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			invalidParam(c, bound.name, "must be an RFC3339 timestamp")
+			return f, false
+		}
+		*bound.dst = &t
+	}
+	f.TitlePrefix = strings.TrimSpace(c.Query("title_prefix"))
+	return f, true
+}
+
+// bindPageRequest reads ?limit=&cursor= and writes a 400 on bad input.
+func bindPageRequest(c *gin.Context) (repository.PageRequest, bool) {
+	page := repository.PageRequest{Cursor: c.Query("cursor")}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			invalidParam(c, "limit", "must be a positive integer")
+			return page, false
+		}
+		page.Limit = n
+	}
+	return page, true
 }
 
 func (h *PostHandler) Search(c *gin.Context) {
 	q := strings.TrimSpace(c.Query("q"))
 	if q == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "BAD_REQUEST", "message": "q required"}})
+		invalidParam(c, "q", "is required")
+		return
+	}
+
+	opts := search.SearchOptions{
+		SearchAfter: c.Query("search_after"),
+		Sort:        c.DefaultQuery("sort", search.SortRelevance),
+		Highlight:   c.DefaultQuery("highlight", "true") != "false",
+	}
+	if opts.Sort != search.SortRelevance && opts.Sort != search.SortCreatedAt {
+		invalidParam(c, "sort", "must be relevance or created_at")
+		return
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		invalidParam(c, "order", "must be asc or desc")
+		return
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"from", &opts.From}, {"size", &opts.Size}} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			invalidParam(c, p.name, "must be a non-negative integer")
+			return
+		}
+		*p.dst = n
+	}
+	if opts.Size > search.MaxSearchSize {
+		invalidParam(c, "size", "must not exceed "+strconv.Itoa(search.MaxSearchSize))
 		return
 	}
-	items, total, err := h.svc.SearchES(c, q)
+	if err := opts.CheckWindow(); err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+
+	out, err := h.svc.Search(c, q, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "INTERNAL", "message": err.Error()}})
+		middleware.Fail(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+	c.JSON(http.StatusOK, out)
 }
 
 func BuildPostHandler(cfg any, svc *service.PostService) *PostHandler { return NewPostHandler(svc) }
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReindexHandler struct {
+	r *service.Reindexer
+}
+
+func NewReindexHandler(r *service.Reindexer) *ReindexHandler {
+	return &ReindexHandler{r: r}
+}
+
+// Run rebuilds the search index and swaps the alias. It runs to completion
+// even if the caller disconnects, so a half-built index is never left
+// behind by a dropped request.
+func (h *ReindexHandler) Run(c *gin.Context) {
+	if h.r == nil {
+		middleware.Fail(c, service.Unavailable("elasticsearch not configured"))
+		return
+	}
+	opts := service.ReindexOptions{DeleteOld: c.Query("delete_old") == "true"}
+	if raw := c.Query("batch"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			invalidParam(c, "batch", "must be a positive integer")
+			return
+		}
+		opts.BatchSize = n
+	}
+
+	rep, err := h.r.Run(context.WithoutCancel(c.Request.Context()), opts)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *PostHandler) ListRevisions(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	revs, err := h.svc.ListRevisions(c, id)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": revs, "total": len(revs)})
+}
+
+func (h *PostHandler) GetRevision(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	rev, ok := pathID(c, "rev")
+	if !ok {
+		return
+	}
+	out, err := h.svc.GetRevision(c, id, rev)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// DiffRevisions compares ?from= and ?to= revisions of a post.
+func (h *PostHandler) DiffRevisions(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var bounds [2]int
+	for i, name := range []string{"from", "to"} {
+		n, err := strconv.Atoi(c.Query(name))
+		if err != nil || n <= 0 {
+			invalidParam(c, name, "must be a revision number")
+			return
+		}
+		bounds[i] = n
+	}
+	out, err := h.svc.DiffRevisions(c, id, bounds[0], bounds[1])
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *PostHandler) Rollback(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	rev, ok := pathID(c, "rev")
+	if !ok {
+		return
+	}
+	p, err := h.svc.Rollback(c, id, rev)
+	if err != nil {
+		middleware.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
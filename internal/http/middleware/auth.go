@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errMissingToken = &service.Error{Kind: service.KindUnauthorized, Message: "missing bearer token"}
+
+// Auth requires a valid Bearer access token and stores the caller in the
+// request context for handlers and services.
+func Auth(tokens *auth.TokenManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || raw == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			Fail(c, errMissingToken)
+			return
+		}
+		p, err := tokens.Parse(strings.TrimSpace(raw), auth.TokenAccess)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			Fail(c, err)
+			return
+		}
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	}
+}
+
+// Require rejects callers holding none of perms under policy.
+func Require(policy auth.Policy, perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := auth.Require(c.Request.Context(), policy, perms...); err != nil {
+			Fail(c, err)
+			return
+		}
+		c.Next()
+	}
+}
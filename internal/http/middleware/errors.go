@@ -3,29 +3,72 @@ package middleware
 import (
 	"net/http"
 
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/logger"
+
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
-func ErrorHandler() gin.HandlerFunc {
+// Problem is an RFC 7807 error body. Code mirrors the domain error kind so
+// clients can branch without parsing detail.
+type Problem struct {
+	Type               string                 `json:"type"`
+	Title              string                 `json:"title"`
+	Status             int                    `json:"status"`
+	Detail             string                 `json:"detail,omitempty"`
+	Instance           string                 `json:"instance,omitempty"`
+	Code               string                 `json:"code"`
+	InvalidParams      []service.InvalidParam `json:"invalid_params,omitempty"`
+	MissingPermissions []string               `json:"missing_permissions,omitempty"`
+}
+
+var kindStatus = map[service.Kind]int{
+	service.KindNotFound:           http.StatusNotFound,
+	service.KindConflict:           http.StatusConflict,
+	service.KindValidation:         http.StatusBadRequest,
+	service.KindUnprocessable:      http.StatusUnprocessableEntity,
+	service.KindForbidden:          http.StatusForbidden,
+	service.KindUnauthorized:       http.StatusUnauthorized,
+	service.KindPreconditionFailed: http.StatusPreconditionFailed,
+	service.KindUnavailable:        http.StatusServiceUnavailable,
+}
+
+// ErrorHandler renders the last error a handler recorded with c.Error as
+// application/problem+json. Errors that are not domain errors are logged
+// and reported as a bare 500 so internals never reach the client.
+func ErrorHandler(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
-		// if handlers wrote an error, format it
-		if len(c.Errors) > 0 {
-			c.JSON(-1, gin.H{
-				"error": gin.H{
-					"code":    "INTERNAL",
-					"message": c.Errors[0].Error(),
-				},
-			})
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		p := Problem{Type: "about:blank", Instance: c.Request.URL.Path}
+		if e := service.AsError(err); e != nil {
+			p.Status = kindStatus[e.Kind]
+			p.Code = string(e.Kind)
+			p.Detail = e.Message
+			p.InvalidParams = e.Params
+			p.MissingPermissions = e.Missing
+		} else {
+			logger.FromContext(c.Request.Context(), log).Error("request failed", zap.String("method", c.Request.Method), zap.String("path", c.FullPath()), zap.Error(err))
+			p.Status = http.StatusInternalServerError
+			p.Code = "INTERNAL"
 		}
+		p.Title = http.StatusText(p.Status)
+		WriteProblem(c, p)
 	}
 }
 
-func JSONError(c *gin.Context, code int, msg string) {
-	c.AbortWithStatusJSON(code, gin.H{
-		"error": gin.H{
-			"code":    http.StatusText(code),
-			"message": msg,
-		},
-	})
+// WriteProblem aborts the request with p as the response body.
+func WriteProblem(c *gin.Context, p Problem) {
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// Fail records err for ErrorHandler and stops the handler chain.
+func Fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
 }
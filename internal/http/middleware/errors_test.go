@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/domain/repository"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/search"
+	"github.com/xuanviet96/seta-training/pkg/textdiff"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		detail  string
+		params  []service.InvalidParam
+		missing []string
+	}{
+		{
+			name: "domain error", err: service.NotFound("post %d not found", 7),
+			status: http.StatusNotFound, code: "NOT_FOUND", detail: "post 7 not found",
+		},
+		{
+			name:   "wrapped domain error",
+			err:    fmt.Errorf("update: %w", service.Conflict("busy")),
+			status: http.StatusConflict, code: "CONFLICT", detail: "busy",
+		},
+		{
+			name:   "validation with params",
+			err:    service.Validation("invalid body", service.InvalidParam{Name: "title", Reason: "is required"}),
+			status: http.StatusBadRequest, code: "VALIDATION", detail: "invalid body",
+			params: []service.InvalidParam{{Name: "title", Reason: "is required"}},
+		},
+		{
+			name:   "precondition failed",
+			err:    service.PreconditionFailed("post changed"),
+			status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED", detail: "post changed",
+		},
+		{
+			name:   "unavailable",
+			err:    service.Unavailable("search down"),
+			status: http.StatusServiceUnavailable, code: "UNAVAILABLE", detail: "search down",
+		},
+		{
+			name:   "invalid credentials",
+			err:    service.ErrInvalidCredentials,
+			status: http.StatusUnauthorized, code: "UNAUTHORIZED", detail: "invalid email or password",
+		},
+		{
+			name:   "idempotency key reused",
+			err:    service.ErrIdempotencyKeyReused,
+			status: http.StatusUnprocessableEntity, code: "UNPROCESSABLE",
+			detail: "Idempotency-Key was already used with a different request",
+		},
+		{
+			name:   "permission error",
+			err:    &auth.PermissionError{Missing: []string{auth.PermPostPurge}},
+			status: http.StatusForbidden, code: "FORBIDDEN", detail: "missing permission " + auth.PermPostPurge,
+			missing: []string{auth.PermPostPurge},
+		},
+		{
+			name:   "record not found",
+			err:    gorm.ErrRecordNotFound,
+			status: http.StatusNotFound, code: "NOT_FOUND", detail: "resource not found",
+		},
+		{
+			name:   "version conflict",
+			err:    repository.ErrVersionConflict,
+			status: http.StatusConflict, code: "CONFLICT", detail: repository.ErrVersionConflict.Error(),
+		},
+		{
+			name:   "invalid cursor",
+			err:    repository.ErrInvalidCursor,
+			status: http.StatusBadRequest, code: "VALIDATION", detail: repository.ErrInvalidCursor.Error(),
+			params: []service.InvalidParam{{Name: "cursor", Reason: "malformed or expired"}},
+		},
+		{
+			name:   "search window exceeded",
+			err:    fmt.Errorf("search: %w", search.ErrWindowExceeded),
+			status: http.StatusBadRequest, code: "VALIDATION", detail: search.ErrWindowExceeded.Error(),
+			params: []service.InvalidParam{{Name: "from", Reason: fmt.Sprintf("from+size exceeds %d", search.MaxResultWindow)}},
+		},
+		{
+			name:   "diff too large",
+			err:    textdiff.ErrTooLarge,
+			status: http.StatusUnprocessableEntity, code: "UNPROCESSABLE",
+			detail: fmt.Sprintf("content too large to diff: at most %d lines per revision and %d changed lines", textdiff.MaxLines, textdiff.MaxEdits),
+		},
+		{
+			name:   "invalid token",
+			err:    auth.ErrInvalidToken,
+			status: http.StatusUnauthorized, code: "UNAUTHORIZED", detail: auth.ErrInvalidToken.Error(),
+		},
+		{
+			name:   "timeout",
+			err:    context.DeadlineExceeded,
+			status: http.StatusServiceUnavailable, code: "UNAVAILABLE", detail: "request timed out",
+		},
+		{
+			name:   "internal error is not leaked",
+			err:    errors.New("pq: connection refused to 10.0.0.5"),
+			status: http.StatusInternalServerError, code: "INTERNAL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(ErrorHandler(zap.NewNop()))
+			r.GET("/posts/7", func(c *gin.Context) { Fail(c, tt.err) })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/7", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", ct)
+			}
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decode body %s: %v", w.Body, err)
+			}
+			want := Problem{
+				Type:               "about:blank",
+				Title:              http.StatusText(tt.status),
+				Status:             tt.status,
+				Detail:             tt.detail,
+				Instance:           "/posts/7",
+				Code:               tt.code,
+				InvalidParams:      tt.params,
+				MissingPermissions: tt.missing,
+			}
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("problem = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler(zap.NewNop()))
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusAccepted, gin.H{"ok": true})
+		_ = c.Error(errors.New("logged only"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != `{"ok":true}` {
+		t.Errorf("got %d %s, want the handler's own 202 response", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// replayedHeaders are kept with a stored response and sent on replay.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency makes requests carrying an Idempotency-Key safe to retry.
+// The first request with a key runs normally and its successful response
+// is stored; retries with the same body get that response back, marked
+// with Idempotent-Replayed. Failed requests are not stored.
+func Idempotency(svc *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(idempotencyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			Fail(c, service.Validation("invalid "+idempotencyHeader,
+				service.InvalidParam{Name: idempotencyHeader, Reason: "must be at most 255 characters"}))
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			Fail(c, service.Validation("unreadable request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		claim, replay, err := svc.Begin(c, key, fingerprint(c.Request.Method, c.FullPath(), body))
+		if err != nil {
+			Fail(c, err)
+			return
+		}
+		if replay != nil {
+			for k, v := range replay.ResponseHeaders {
+				c.Header(k, v)
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(replay.ResponseCode, replay.ResponseHeaders["Content-Type"], replay.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		stop := svc.Hold(c, claim)
+		done := false
+		defer func() {
+			// a panicking handler must not keep the key locked
+			if !done {
+				stop()
+				svc.Release(c, claim)
+			}
+		}()
+		c.Next()
+		done = true
+		stop()
+
+		if len(c.Errors) > 0 || !rec.Written() || rec.Status() >= 500 {
+			svc.Release(c, claim)
+			return
+		}
+		headers := make(map[string]string, len(replayedHeaders))
+		for _, h := range replayedHeaders {
+			if v := rec.Header().Get(h); v != "" {
+				headers[h] = v
+			}
+		}
+		svc.Complete(c, claim, rec.Status(), headers, rec.body.Bytes())
+	}
+}
+
+func fingerprint(method, route string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + route + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyRecorder keeps a copy of what the handler writes.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
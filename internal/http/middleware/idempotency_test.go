@@ -0,0 +1,312 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/domain/models"
+	service "github.com/xuanviet96/seta-training/internal/domain/services"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := fingerprint(http.MethodPost, "/v1/posts", []byte(`{"title":"a"}`))
+	tests := []struct {
+		name   string
+		method string
+		route  string
+		body   string
+		same   bool
+	}{
+		{name: "identical", method: http.MethodPost, route: "/v1/posts", body: `{"title":"a"}`, same: true},
+		{name: "other body", method: http.MethodPost, route: "/v1/posts", body: `{"title":"b"}`},
+		{name: "other method", method: http.MethodPut, route: "/v1/posts", body: `{"title":"a"}`},
+		{name: "other route", method: http.MethodPost, route: "/v1/posts:bulk", body: `{"title":"a"}`},
+		{name: "empty body", method: http.MethodPost, route: "/v1/posts", body: ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fingerprint(tt.method, tt.route, []byte(tt.body))
+			if len(got) != 64 {
+				t.Errorf("fingerprint %q is not a hex sha256", got)
+			}
+			if (got == base) != tt.same {
+				t.Errorf("fingerprint equal to base = %v, want %v", got == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	alice := &auth.Principal{UserID: 1, Role: auth.RoleAuthor}
+	bob := &auth.Principal{UserID: 2, Role: auth.RoleAuthor}
+
+	type call struct {
+		key    string
+		body   string
+		caller *auth.Principal
+		fail   bool // the handler reports a validation error
+
+		status   int
+		replayed bool
+		runs     int // handler runs so far, after this call
+	}
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{
+			name: "retry is replayed",
+			calls: []call{
+				{key: "k1", body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, runs: 1},
+				{key: "k1", body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, replayed: true, runs: 1},
+				{key: "k1", body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, replayed: true, runs: 1},
+			},
+		},
+		{
+			name: "no key runs every time",
+			calls: []call{
+				{body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, runs: 1},
+				{body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, runs: 2},
+			},
+		},
+		{
+			name: "key reused with another body",
+			calls: []call{
+				{key: "k1", body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, runs: 1},
+				{key: "k1", body: `{"title":"b"}`, caller: alice, status: http.StatusUnprocessableEntity, runs: 1},
+			},
+		},
+		{
+			name: "keys are per user",
+			calls: []call{
+				{key: "k1", body: `{"title":"a"}`, caller: alice, status: http.StatusCreated, runs: 1},
+				{key: "k1", body: `{"title":"a"}`, caller: bob, status: http.StatusCreated, runs: 2},
+			},
+		},
+		{
+			name: "failure is not stored",
+			calls: []call{
+				{key: "k1", body: `{}`, caller: alice, fail: true, status: http.StatusBadRequest, runs: 1},
+				{key: "k1", body: `{}`, caller: alice, status: http.StatusCreated, runs: 2},
+				{key: "k1", body: `{}`, caller: alice, status: http.StatusCreated, replayed: true, runs: 2},
+			},
+		},
+		{
+			name: "key too long",
+			calls: []call{
+				{key: strings.Repeat("k", 256), body: `{}`, caller: alice, status: http.StatusBadRequest, runs: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Defaults()
+			svc := service.NewIdempotencyService(cfg, zap.NewNop(), nil, newMemIdempotencyRepo())
+			runs := 0
+			fail := false
+			var caller *auth.Principal
+
+			r := gin.New()
+			r.ContextWithFallback = true
+			r.Use(ErrorHandler(zap.NewNop()), func(c *gin.Context) {
+				c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), caller))
+			}, Idempotency(svc))
+			r.POST("/v1/posts", func(c *gin.Context) {
+				runs++
+				if fail {
+					Fail(c, service.Validation("title is required"))
+					return
+				}
+				c.Header("ETag", `"1"`)
+				c.Header("Location", "/v1/posts/9")
+				c.Header("X-Not-Replayed", "1")
+				c.JSON(http.StatusCreated, gin.H{"id": 9, "run": runs})
+			})
+
+			var first *httptest.ResponseRecorder
+			for i, cl := range tt.calls {
+				caller, fail = cl.caller, cl.fail
+				req := httptest.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(cl.body))
+				req.Header.Set("Content-Type", "application/json")
+				if cl.key != "" {
+					req.Header.Set("Idempotency-Key", cl.key)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != cl.status {
+					t.Fatalf("call %d: status = %d, want %d (%s)", i, w.Code, cl.status, w.Body)
+				}
+				if runs != cl.runs {
+					t.Errorf("call %d: handler ran %d times, want %d", i, runs, cl.runs)
+				}
+				if got := w.Header().Get("Idempotent-Replayed") == "true"; got != cl.replayed {
+					t.Errorf("call %d: replayed = %v, want %v", i, got, cl.replayed)
+				}
+				if !cl.replayed {
+					if w.Code == http.StatusCreated {
+						first = w
+					}
+					continue
+				}
+				if w.Body.String() != first.Body.String() {
+					t.Errorf("call %d: body = %s, want the original %s", i, w.Body, first.Body)
+				}
+				for _, h := range []string{"Content-Type", "ETag", "Location"} {
+					if w.Header().Get(h) != first.Header().Get(h) {
+						t.Errorf("call %d: %s = %q, want %q", i, h, w.Header().Get(h), first.Header().Get(h))
+					}
+				}
+				if w.Header().Get("X-Not-Replayed") != "" {
+					t.Errorf("call %d: replayed a header outside the allow list", i)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyHoldsLockWhileRunning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Defaults()
+	cfg.Idempotency.LockTTL = 60 * time.Millisecond
+	svc := service.NewIdempotencyService(cfg, zap.NewNop(), nil, newMemIdempotencyRepo())
+	var runs atomic.Int32
+	started := make(chan struct{}, 1)
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(ErrorHandler(zap.NewNop()), func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), &auth.Principal{UserID: 1, Role: auth.RoleAuthor}))
+	}, Idempotency(svc))
+	r.POST("/v1/posts", func(c *gin.Context) {
+		runs.Add(1)
+		started <- struct{}{}
+		// outlive the lock TTL several times over
+		time.Sleep(300 * time.Millisecond)
+		c.JSON(http.StatusCreated, gin.H{"id": 9})
+	})
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(`{"title":"a"}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- post() }()
+	<-started
+	time.Sleep(2 * cfg.Idempotency.LockTTL)
+	retry := post()
+
+	if w := <-first; w.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, want 201", w.Code)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want once", n)
+	}
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: status %d replayed %q, want the replayed 201", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+// memIdempotencyRepo keeps keys in memory with the semantics of the
+// Postgres repository that the middleware relies on.
+type memIdempotencyRepo struct {
+	mu     sync.Mutex
+	nextID int
+	rows   map[int]*models.IdempotencyKey
+}
+
+func newMemIdempotencyRepo() *memIdempotencyRepo {
+	return &memIdempotencyRepo{rows: map[int]*models.IdempotencyKey{}}
+}
+
+func (r *memIdempotencyRepo) find(userID int, key string) *models.IdempotencyKey {
+	for _, rec := range r.rows {
+		if rec.UserID == userID && rec.Key == key {
+			return rec
+		}
+	}
+	return nil
+}
+
+func (r *memIdempotencyRepo) Claim(_ context.Context, _ *gorm.DB, rec *models.IdempotencyKey) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cur := r.find(rec.UserID, rec.Key); cur != nil {
+		now := time.Now()
+		switch {
+		case !cur.ExpiresAt.After(now):
+			delete(r.rows, cur.ID)
+		case cur.Fingerprint == rec.Fingerprint && cur.Status == models.IdempotencyProcessing && cur.LockedUntil.Before(now):
+			// take over a lock whose holder is gone
+			cur.LockedUntil = rec.LockedUntil
+			rec.ID, rec.Status = cur.ID, cur.Status
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+	r.nextID++
+	rec.ID, rec.Status = r.nextID, models.IdempotencyProcessing
+	cp := *rec
+	r.rows[rec.ID] = &cp
+	return true, nil
+}
+
+func (r *memIdempotencyRepo) Get(_ context.Context, _ *gorm.DB, userID int, key string) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur := r.find(userID, key)
+	if cur == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *cur
+	return &cp, nil
+}
+
+func (r *memIdempotencyRepo) Extend(_ context.Context, _ *gorm.DB, id int, lockedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.rows[id]
+	if !ok || rec.Status != models.IdempotencyProcessing {
+		return false, nil
+	}
+	rec.LockedUntil = lockedUntil
+	return true, nil
+}
+
+func (r *memIdempotencyRepo) Complete(_ context.Context, _ *gorm.DB, id, code int, headers map[string]string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rec, ok := r.rows[id]; ok {
+		rec.Status, rec.ResponseCode, rec.ResponseHeaders = models.IdempotencyDone, code, headers
+		rec.ResponseBody = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (r *memIdempotencyRepo) Release(_ context.Context, _ *gorm.DB, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rows, id)
+	return nil
+}
+
+func (r *memIdempotencyRepo) PurgeExpired(context.Context, *gorm.DB) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+	maxRequestIDLen = 128
+)
+
+// RequestID keeps the caller's X-Request-ID, or assigns one, echoes it on
+// the response and stores a logger tagged with it in the request context.
+func RequestID(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(HeaderRequestID, id)
+
+		fields := []zap.Field{zap.String("request_id", id)}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+		}
+		ctx := logger.WithContext(c.Request.Context(), log.With(fields...))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// AccessLog writes one line per request once it has been handled. Routes
+// in skip, such as probes, are not logged.
+func AccessLog(skip ...string) gin.HandlerFunc {
+	quiet := make(map[string]bool, len(skip))
+	for _, route := range skip {
+		quiet[route] = true
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if quiet[c.FullPath()] {
+			return
+		}
+
+		log := logger.FromContext(c.Request.Context(), zap.NewNop())
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("client_ip", c.ClientIP()),
+		}
+		if p := auth.FromContext(c.Request.Context()); p != nil {
+			fields = append(fields, zap.Int("user_id", p.UserID))
+		}
+		if c.Writer.Status() >= 500 {
+			log.Error("request", fields...)
+			return
+		}
+		log.Info("request", fields...)
+	}
+}
+
+// validRequestID accepts caller IDs of printable ASCII so they are safe to
+// echo in headers and logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -1,44 +1,137 @@
 package httpserver
 
 import (
+	"github.com/xuanviet96/seta-training/internal/auth"
+	"github.com/xuanviet96/seta-training/internal/cache"
 	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/database"
 	"github.com/xuanviet96/seta-training/internal/domain/repository"
 	service "github.com/xuanviet96/seta-training/internal/domain/services"
+	"github.com/xuanviet96/seta-training/internal/health"
 	"github.com/xuanviet96/seta-training/internal/http/handlers"
 	"github.com/xuanviet96/seta-training/internal/http/middleware"
+	"github.com/xuanviet96/seta-training/internal/lifecycle"
+	"github.com/xuanviet96/seta-training/internal/metrics"
 	search "github.com/xuanviet96/seta-training/internal/search"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-func NewRouter(cfg config.Config, log *zap.Logger, gdb *gorm.DB, rdb *redis.Client, es *search.ESClient) *gin.Engine {
-	if cfg.AppEnv == "production" {
+func NewRouter(cfg config.Config, log *zap.Logger, gdb *gorm.DB, replicas *database.Replicas, cc cache.Cache, es *search.ESClient, outbox *service.OutboxDispatcher, tokens *auth.TokenManager, policy auth.Policy, lc *lifecycle.Lifecycle, level zap.AtomicLevel) *gin.Engine {
+	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.New()
-	r.Use(gin.Recovery(), middleware.ErrorHandler())
+	// let c.Value reach the request context, where auth stores the caller
+	r.ContextWithFallback = true
+	r.Use(
+		gin.Recovery(),
+		middleware.Tracing(),
+		middleware.RequestID(log),
+		middleware.AccessLog("/livez", "/readyz", "/health", "/metrics"),
+		middleware.Metrics(),
+		middleware.ErrorHandler(log),
+	)
 
 	// health
-	health := handlers.NewHealthHandler(gdb, rdb, es)
-	r.GET("/health", health.Get)
+	hh := handlers.NewHealthHandler(gdb, cc, es, lc, health.Options{
+		Critical: cfg.Health.Critical,
+		Timeout:  cfg.Health.CheckTimeout,
+		CacheTTL: cfg.Health.CacheTTL,
+	})
+	r.GET("/livez", hh.Livez)
+	r.GET("/readyz", hh.Readyz)
+	// kept for existing monitors; same as /readyz
+	r.GET("/health", hh.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// posts
 	repo := repository.NewPostRepository()
-	svc := service.NewPostService(cfg, log, gdb, rdb, repo, es)
+	outboxRepo := repository.NewOutboxRepository()
+	revisions := repository.NewRevisionRepository()
+	// Postgres full-text search takes over whenever ES is missing or unhealthy
+	var primary search.Backend
+	if es != nil {
+		primary = search.NewESBackend(es, cfg.ES.Index)
+	}
+	searcher := search.NewFallback(primary, search.NewPostgresBackend(gdb), log)
+	svc := service.NewPostService(cfg, log, gdb, replicas, cc, repo, outboxRepo, revisions, searcher, policy, lc)
 	ph := handlers.NewPostHandler(svc)
+	idem := middleware.Idempotency(service.NewIdempotencyService(cfg, log, gdb, repository.NewIdempotencyRepository()))
+	oh := handlers.NewOutboxHandler(outbox)
+
+	var reindexer *service.Reindexer
+	if es != nil {
+		reindexer = service.NewReindexer(cfg, log, gdb, repo, outboxRepo, es)
+	}
+	rh := handlers.NewReindexHandler(reindexer)
+	eh := handlers.NewExportHandler(service.NewExporter(log, gdb, repo, policy))
+	lh := handlers.NewLogLevelHandler(log, level)
+	ih := handlers.NewImportHandler(service.NewImporter(cfg, log, gdb, repo, revisions, outboxRepo, es, policy, svc))
+
+	// auth (public)
+	ah := handlers.NewAuthHandler(service.NewAuthService(log, gdb, repository.NewUserRepository(), tokens))
+	authGroup := r.Group("/v1/auth")
+	{
+		authGroup.POST("/register", ah.Register)
+		authGroup.POST("/login", ah.Login)
+		authGroup.POST("/refresh", ah.Refresh)
+	}
+
+	// every /v1 route needs a caller and a permission from the policy
+	can := func(perms ...string) gin.HandlerFunc { return middleware.Require(policy, perms...) }
 
-	v1 := r.Group("/v1")
+	v1 := r.Group("/v1", middleware.Auth(tokens))
 	{
-		v1.POST("/posts", ph.Create)
-		v1.GET("/posts/:id", ph.GetByID)
-		v1.PUT("/posts/:id", ph.Update)
-		v1.GET("/posts/search-by-tag", ph.SearchByTag)
-		v1.GET("/posts/search", ph.Search)
+		v1.POST("/posts", can(auth.PermPostCreate), idem, ph.Create)
+		v1.POST("/posts:method", customMethods(map[string]gin.HandlersChain{
+			":bulk": {can(auth.PermPostImport), ih.Bulk},
+		}))
+		v1.GET("/posts", can(auth.PermPostRead), ph.List)
+		v1.GET("/posts/export", can(auth.PermPostExport), eh.Export)
+		v1.GET("/posts/:id", can(auth.PermPostRead), ph.GetByID)
+		v1.PUT("/posts/:id", can(auth.PermPostUpdateOwn, auth.PermPostUpdateAny), ph.Update)
+		v1.DELETE("/posts/:id", can(auth.PermPostDeleteOwn, auth.PermPostDeleteAny), ph.Delete)
+		v1.POST("/posts/:id/restore", can(auth.PermPostRestoreOwn, auth.PermPostRestoreAny), ph.Restore)
+		v1.GET("/posts/:id/revisions", can(auth.PermPostRead), ph.ListRevisions)
+		v1.GET("/posts/:id/revisions/diff", can(auth.PermPostRead), ph.DiffRevisions)
+		v1.GET("/posts/:id/revisions/:rev", can(auth.PermPostRead), ph.GetRevision)
+		v1.POST("/posts/:id/revisions/:rev/rollback", can(auth.PermPostUpdateOwn, auth.PermPostUpdateAny), ph.Rollback)
+		v1.GET("/posts/search-by-tag", can(auth.PermPostRead), ph.SearchByTag)
+		v1.GET("/posts/search", can(auth.PermPostRead), ph.Search)
+	}
+
+	admin := v1.Group("/admin")
+	{
+		admin.DELETE("/posts/:id", can(auth.PermPostPurge), ph.Purge)
+		admin.GET("/outbox", can(auth.PermOutboxManage), oh.Metrics)
+		admin.POST("/outbox/:id/requeue", can(auth.PermOutboxManage), oh.Requeue)
+		admin.POST("/search/reindex", can(auth.PermSearchReindex), rh.Run)
+		admin.GET("/log-level", can(auth.PermLogManage), lh.Get)
+		admin.PUT("/log-level", can(auth.PermLogManage), lh.Set)
 	}
 
 	return r
 }
+
+// customMethods routes Google-style custom methods such as /posts:bulk.
+// gin cannot match a literal ':' inside a segment, so they share one
+// wildcard route whose value is the ":verb" suffix.
+func customMethods(methods map[string]gin.HandlersChain) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chain, ok := methods[c.Param("method")]
+		if !ok {
+			middleware.Fail(c, service.NotFound("no route for %s", c.Request.URL.Path))
+			return
+		}
+		for _, h := range chain {
+			if c.IsAborted() {
+				return
+			}
+			h(c)
+		}
+	}
+}
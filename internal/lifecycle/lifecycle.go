@@ -0,0 +1,69 @@
+// Package lifecycle coordinates shutdown: it tells probes the process is
+// going away and tracks background goroutines that must finish first.
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type Lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	stopped  bool
+	draining atomic.Bool
+}
+
+func New() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a tracked goroutine. fn's context is cancelled when Wait
+// is called. Once Wait has started no new work is accepted and Go reports
+// false.
+func (l *Lifecycle) Go(fn func(ctx context.Context)) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return false
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.ctx)
+	}()
+	return true
+}
+
+// BeginShutdown marks the process as draining so readiness checks fail
+// while in-flight requests finish.
+func (l *Lifecycle) BeginShutdown() { l.draining.Store(true) }
+
+func (l *Lifecycle) ShuttingDown() bool { return l.draining.Load() }
+
+// Wait cancels background work and blocks until every goroutine started
+// with Go has returned, or ctx is done.
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	l.draining.Store(true)
+	l.mu.Lock()
+	l.stopped = true
+	l.mu.Unlock()
+	l.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,12 +1,109 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
-func New(env string) *zap.Logger {
-	if env == "production" {
-		l, _ := zap.NewProduction()
-		return l
+	"github.com/xuanviet96/seta-training/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// New builds the service logger from cfg. The returned level can be changed
+// at runtime and affects every logger derived from the result.
+func New(cfg config.Config) (*zap.Logger, zap.AtomicLevel, error) {
+	prod := cfg.Env == "production"
+
+	level := zap.NewAtomicLevelAt(zap.DebugLevel)
+	if prod {
+		level.SetLevel(zap.InfoLevel)
+	}
+	if cfg.Log.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+			return nil, level, fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+	}
+
+	format := cfg.Log.Format
+	if format == "" {
+		format = FormatConsole
+		if prod {
+			format = FormatJSON
+		}
+	}
+	var enc zapcore.Encoder
+	switch format {
+	case FormatJSON:
+		ec := zap.NewProductionEncoderConfig()
+		ec.EncodeTime = zapcore.ISO8601TimeEncoder
+		enc = zapcore.NewJSONEncoder(ec)
+	case FormatConsole:
+		ec := zap.NewDevelopmentEncoderConfig()
+		if !prod {
+			ec.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		enc = zapcore.NewConsoleEncoder(ec)
+	default:
+		return nil, level, fmt.Errorf("LOG_FORMAT: unknown format %q", format)
+	}
+
+	out, err := outputs(cfg)
+	if err != nil {
+		return nil, level, err
+	}
+
+	var core zapcore.Core = zapcore.NewCore(enc, out, level)
+	if len(cfg.Log.Redact) > 0 {
+		core = newRedactCore(core, cfg.Log.Redact)
+	}
+	// identical messages past the first LogSamplingInitial per second are
+	// thinned to one in LogSamplingThereafter
+	if cfg.Log.SamplingInitial > 0 && cfg.Log.SamplingThereafter > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.Log.SamplingInitial, cfg.Log.SamplingThereafter)
+	}
+
+	opts := []zap.Option{zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel), zap.ErrorOutput(zapcore.Lock(os.Stderr))}
+	if !prod {
+		opts = append(opts, zap.Development())
+	}
+	return zap.New(core, opts...), level, nil
+}
+
+// outputs opens every LOG_OUTPUT target. Anything but stdout and stderr is
+// a file rotated by size and age.
+func outputs(cfg config.Config) (zapcore.WriteSyncer, error) {
+	paths := cfg.Log.Output
+	if len(paths) == 0 {
+		paths = []string{"stdout"}
+	}
+	ws := make([]zapcore.WriteSyncer, 0, len(paths))
+	for _, p := range paths {
+		switch p {
+		case "stdout":
+			ws = append(ws, zapcore.Lock(os.Stdout))
+		case "stderr":
+			ws = append(ws, zapcore.Lock(os.Stderr))
+		default:
+			if strings.TrimSpace(p) == "" {
+				return nil, fmt.Errorf("LOG_OUTPUT: empty path")
+			}
+			ws = append(ws, zapcore.AddSync(&lumberjack.Logger{
+				Filename:   p,
+				MaxSize:    cfg.Log.MaxSizeMB,
+				MaxAge:     cfg.Log.MaxAgeDays,
+				MaxBackups: cfg.Log.MaxBackups,
+				Compress:   true,
+			}))
+		}
 	}
-	l, _ := zap.NewDevelopment()
-	return l
+	return zapcore.NewMultiWriteSyncer(ws...), nil
 }
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+// redactCore masks the values of fields whose key is on the list, matched
+// case-insensitively. Only top-level fields are inspected.
+type redactCore struct {
+	zapcore.Core
+	keys map[string]bool
+}
+
+func newRedactCore(core zapcore.Core, keys []string) zapcore.Core {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = true
+	}
+	return &redactCore{Core: core, keys: set}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redact(fields)), keys: c.keys}
+}
+
+func (c *redactCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(e, c.redact(fields))
+}
+
+func (c *redactCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		if !c.keys[strings.ToLower(f.Key)] {
+			continue
+		}
+		if out == nil {
+			// copy on first hit; the caller owns fields
+			out = append([]zapcore.Field(nil), fields...)
+		}
+		out[i] = zap.String(f.Key, redacted)
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+// Package metrics holds the Prometheus collectors exposed on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is private to the service so tests and subcommands don't share
+// the global default registry.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Gorm statement latency by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Gorm statements that failed, excluding record not found.",
+	}, []string{"operation", "table"})
+
+	PostCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "post_cache_requests_total",
+		Help: "Post cache lookups by GetByID, by result (hit, stale, miss, error).",
+	}, []string{"result"})
+
+	SearchRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "search_request_duration_seconds",
+		Help:    "Elasticsearch request latency by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	SearchRequestFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "search_request_failures_total",
+		Help: "Elasticsearch requests that failed, by operation.",
+	}, []string{"operation"})
+
+	IndexJobsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "search_index_jobs_in_flight",
+		Help: "Outbox events currently being delivered to Elasticsearch.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		DBQueryDuration,
+		DBQueryErrors,
+		PostCacheRequests,
+		SearchRequestDuration,
+		SearchRequestFailures,
+		IndexJobsInFlight,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveSearch records one Elasticsearch request that started at start.
+func ObserveSearch(op string, start time.Time, err error) {
+	SearchRequestDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		SearchRequestFailures.WithLabelValues(op).Inc()
+	}
+}
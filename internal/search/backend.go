@@ -0,0 +1,189 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/logger"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	BackendElasticsearch = "elasticsearch"
+	BackendPostgres      = "postgres"
+)
+
+// Backend runs full-text queries over posts.
+type Backend interface {
+	Name() string
+	Healthy(ctx context.Context) error
+	Search(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error)
+}
+
+const (
+	SortRelevance = "relevance"
+	SortCreatedAt = "created_at"
+
+	MaxSearchSize = 100
+	// ES refuses from+size beyond index.max_result_window (10k by default);
+	// deeper pages must use SearchAfter.
+	MaxResultWindow = 10000
+)
+
+var (
+	ErrInvalidSearchAfter = errors.New("invalid search_after token")
+	// ErrWindowExceeded rejects From+Size beyond MaxResultWindow.
+	ErrWindowExceeded = fmt.Errorf("from+size must not exceed %d, use search_after for deep paging", MaxResultWindow)
+)
+
+// SearchOptions controls paging, ordering and scoring of a search.
+// SearchAfter, when set, takes precedence over From.
+type SearchOptions struct {
+	From         int
+	Size         int
+	SearchAfter  string
+	Sort         string // SortRelevance (default) or SortCreatedAt
+	Ascending    bool
+	TitleBoost   float64
+	ContentBoost float64
+	Highlight    bool
+}
+
+// SearchHit is a matched document with its score and highlighted snippets
+// keyed by field name.
+type SearchHit struct {
+	PostDoc
+	Score     *float64            `json:"score,omitempty"`
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+type SearchResult struct {
+	Items []SearchHit `json:"items"`
+	Total int         `json:"total"`
+	// Backend names the implementation that answered, so clients can tell
+	// when results come from the degraded fallback.
+	Backend string `json:"backend"`
+	// Next is an opaque search_after token for the following page, empty on
+	// the last page.
+	Next string `json:"next,omitempty"`
+}
+
+func (o SearchOptions) size() int {
+	switch {
+	case o.Size <= 0:
+		return 10
+	case o.Size > MaxSearchSize:
+		return MaxSearchSize
+	}
+	return o.Size
+}
+
+// CheckWindow returns ErrWindowExceeded when From plus the effective page
+// size reaches past MaxResultWindow. SearchAfter paging has no such limit.
+func (o SearchOptions) CheckWindow() error {
+	if o.SearchAfter == "" && o.From+o.size() > MaxResultWindow {
+		return ErrWindowExceeded
+	}
+	return nil
+}
+
+const (
+	// healthTTL is how long a primary health check result is trusted.
+	healthTTL = 10 * time.Second
+	// healthTimeout bounds one primary health probe.
+	healthTimeout = 2 * time.Second
+)
+
+// Fallback sends queries to primary while it is healthy and to secondary
+// otherwise. A nil primary always uses secondary.
+type Fallback struct {
+	primary   Backend
+	secondary Backend
+	log       *zap.Logger
+
+	probes singleflight.Group
+	health atomic.Pointer[health]
+}
+
+// health is the outcome of one primary probe.
+type health struct {
+	ok        bool
+	checkedAt time.Time
+}
+
+func NewFallback(primary, secondary Backend, log *zap.Logger) *Fallback {
+	return &Fallback{primary: primary, secondary: secondary, log: log}
+}
+
+func (f *Fallback) Name() string {
+	if f.primary == nil {
+		return f.secondary.Name()
+	}
+	return f.primary.Name()
+}
+
+// Healthy reports whether any backend can serve queries.
+func (f *Fallback) Healthy(ctx context.Context) error {
+	if f.primaryHealthy(ctx) {
+		return nil
+	}
+	return f.secondary.Healthy(ctx)
+}
+
+func (f *Fallback) Search(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	if f.primaryHealthy(ctx) {
+		res, err := f.primary.Search(ctx, query, opts)
+		// Errors in the request itself would fail on the fallback too and
+		// say nothing about the primary's health
+		if err == nil || errors.Is(err, ErrInvalidSearchAfter) || errors.Is(err, ErrWindowExceeded) || ctx.Err() != nil {
+			return res, err
+		}
+		logger.FromContext(ctx, f.log).Warn("primary search backend failed, falling back",
+			zap.String("primary", f.primary.Name()), zap.String("fallback", f.secondary.Name()), zap.Error(err))
+		f.markUnhealthy()
+	}
+	return f.secondary.Search(ctx, query, opts)
+}
+
+// primaryHealthy returns the cached probe result, probing again once it is
+// older than healthTTL. Concurrent callers share one probe, which runs
+// detached from ctx so a cancelled request cannot mark the primary down.
+// A caller that gives up waiting gets the previous result.
+func (f *Fallback) primaryHealthy(ctx context.Context) bool {
+	if f.primary == nil {
+		return false
+	}
+	last := f.health.Load()
+	if last != nil && time.Since(last.checkedAt) < healthTTL {
+		return last.ok
+	}
+	ch := f.probes.DoChan("primary", func() (any, error) {
+		return f.probe(context.WithoutCancel(ctx)), nil
+	})
+	select {
+	case res := <-ch:
+		return res.Val.(bool)
+	case <-ctx.Done():
+		return last != nil && last.ok
+	}
+}
+
+func (f *Fallback) probe(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	err := f.primary.Healthy(ctx)
+	if last := f.health.Load(); err != nil && (last == nil || last.ok) {
+		logger.FromContext(ctx, f.log).Warn("search backend unhealthy, using fallback", zap.String("backend", f.primary.Name()), zap.Error(err))
+	}
+	f.health.Store(&health{ok: err == nil, checkedAt: time.Now()})
+	return err == nil
+}
+
+func (f *Fallback) markUnhealthy() {
+	f.health.Store(&health{checkedAt: time.Now()})
+}
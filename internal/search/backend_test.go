@@ -0,0 +1,28 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchOptionsCheckWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want error
+	}{
+		{name: "first page", opts: SearchOptions{}},
+		{name: "last full page", opts: SearchOptions{From: MaxResultWindow - 10, Size: 10}},
+		{name: "past the window", opts: SearchOptions{From: MaxResultWindow - 5, Size: 10}, want: ErrWindowExceeded},
+		{name: "default size counts", opts: SearchOptions{From: MaxResultWindow - 5}, want: ErrWindowExceeded},
+		{name: "size clamped to max", opts: SearchOptions{From: MaxResultWindow - MaxSearchSize, Size: 500}},
+		{name: "search_after ignores from", opts: SearchOptions{From: MaxResultWindow, SearchAfter: "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.CheckWindow(); !errors.Is(err, tt.want) {
+				t.Errorf("CheckWindow() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,11 +3,14 @@ package search
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/xuanviet96/seta-training/internal/config"
 
@@ -16,74 +19,116 @@ import (
 )
 
 type ESClient struct {
-	Client *elastic.Client
+	Client    *elastic.Client
+	transport *http.Transport
+	// ready is set once EnsureIndex has succeeded
+	ready atomic.Bool
 }
 
+// New returns a nil client when es.addr is empty, which leaves search to
+// Postgres.
 func New(cfg config.Config, log *zap.Logger) (*ESClient, error) {
-	if cfg.ESAddr == "" {
-		return nil, errors.New("ES_ADDR empty")
+	if cfg.ES.Addr == "" {
+		log.Info("elasticsearch disabled, searching Postgres only")
+		return nil, nil
 	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	es, err := elastic.NewClient(elastic.Config{
-		Addresses: []string{cfg.ESAddr},
+		Addresses: []string{cfg.ES.Addr},
+		Transport: transport,
 	})
 	if err != nil {
 		return nil, err
 	}
-	// ping
-	res, err := es.Info()
-	if err != nil {
-		return nil, err
+	log.Info("elasticsearch client configured", zap.String("addr", cfg.ES.Addr))
+	return &ESClient{Client: es, transport: transport}, nil
+}
+
+// Ready reports whether the index alias is known to be in place. Until
+// then writes must not go to ES, where they would create a concrete index
+// under the alias name.
+func (es *ESClient) Ready() bool { return es.ready.Load() }
+
+// Close drops the client's idle connections. In-flight requests finish.
+func (es *ESClient) Close() {
+	if es.transport != nil {
+		es.transport.CloseIdleConnections()
 	}
-	defer res.Body.Close()
-	log.Info("connected to elasticsearch", zap.String("addr", cfg.ESAddr))
-	return &ESClient{Client: es}, nil
 }
 
-func EnsureIndex(ctx context.Context, es *ESClient, index string, log *zap.Logger) error {
-	// check exists
-	res, err := es.Client.Indices.Exists([]string{index})
+// EnsureIndex makes sure alias resolves to a posts index and marks es
+// ready. On a fresh cluster it creates <alias>_v1 with the current mapping
+// and points the alias at it. A legacy concrete index named like the alias
+// is left alone until the next Reindex migrates it.
+func EnsureIndex(ctx context.Context, es *ESClient, alias string, log *zap.Logger) (err error) {
+	defer func() {
+		if err == nil {
+			es.ready.Store(true)
+		}
+	}()
+	indices, err := AliasIndices(ctx, es, alias)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-	if res.StatusCode == http.StatusOK {
+	if len(indices) > 0 {
 		return nil
 	}
-	// create with mapping
-	body := map[string]any{
-		"mappings": map[string]any{
-			"properties": map[string]any{
-				"id":      map[string]any{"type": "integer"},
-				"title":   map[string]any{"type": "text", "fields": map[string]any{"keyword": map[string]any{"type": "keyword"}}},
-				"content": map[string]any{"type": "text"},
-				"tags":    map[string]any{"type": "keyword"},
-			},
-		},
-	}
-	buf, _ := json.Marshal(body)
-	cr, err := es.Client.Indices.Create(index, es.Client.Indices.Create.WithBody(bytes.NewReader(buf)))
+	legacy, err := IndexExists(ctx, es, alias)
 	if err != nil {
 		return err
 	}
-	defer cr.Body.Close()
-	if cr.IsError() {
-		b, _ := io.ReadAll(cr.Body)
-		return fmt.Errorf("create index error: %s", string(b))
+	if legacy {
+		log.Warn("es index is not behind an alias, run reindex to migrate it", zap.String("index", alias))
+		return nil
 	}
-	log.Info("created es index", zap.String("index", index))
+
+	index := VersionedIndex(alias, 1)
+	if err := CreateIndex(ctx, es, index); err != nil {
+		return err
+	}
+	if err := SwapAlias(ctx, es, alias, index, nil); err != nil {
+		return err
+	}
+	log.Info("created es index", zap.String("index", index), zap.String("alias", alias))
 	return nil
 }
 
+// WaitIndex retries EnsureIndex with backoff until it succeeds or ctx is
+// done, for an ES that was not reachable at startup.
+func WaitIndex(ctx context.Context, es *ESClient, alias string, log *zap.Logger) error {
+	wait := time.Second
+	for {
+		err := EnsureIndex(ctx, es, alias, log)
+		if err == nil {
+			log.Info("elasticsearch reachable", zap.String("index", alias))
+			return nil
+		}
+		log.Debug("elasticsearch still unreachable", zap.Duration("retry_in", wait), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, 30*time.Second)
+	}
+}
+
 type PostDoc struct {
-	ID      int      `json:"id"`
-	Title   string   `json:"title"`
-	Content string   `json:"content"`
-	Tags    []string `json:"tags,omitempty"`
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
-func IndexPost(ctx context.Context, es *ESClient, index string, doc PostDoc) error {
+func IndexPost(ctx context.Context, es *ESClient, index string, doc PostDoc) (err error) {
+	ctx, done := instrument(ctx, "index")
+	defer func() { done(err) }()
 	data, _ := json.Marshal(doc)
-	res, err := es.Client.Index(index, bytes.NewReader(data), es.Client.Index.WithDocumentID(fmt.Sprint(doc.ID)))
+	res, err := es.Client.Index(index, bytes.NewReader(data),
+		es.Client.Index.WithContext(ctx),
+		es.Client.Index.WithDocumentID(fmt.Sprint(doc.ID)),
+	)
 	if err != nil {
 		return err
 	}
@@ -95,28 +140,82 @@ func IndexPost(ctx context.Context, es *ESClient, index string, doc PostDoc) err
 	return nil
 }
 
-func SearchPosts(ctx context.Context, es *ESClient, index, query string) ([]PostDoc, int, error) {
+func (o SearchOptions) sort() []any {
+	order := "desc"
+	if o.Ascending {
+		order = "asc"
+	}
+	// id breaks ties so search_after is stable across pages
+	tie := map[string]any{"id": map[string]any{"order": "desc"}}
+	if o.Sort == SortCreatedAt {
+		return []any{
+			map[string]any{"created_at": map[string]any{"order": order, "unmapped_type": "date"}},
+			tie,
+		}
+	}
+	return []any{map[string]any{"_score": map[string]any{"order": order}}, tie}
+}
+
+func boosted(field string, boost float64) string {
+	if boost <= 0 || boost == 1 {
+		return field
+	}
+	return field + "^" + strconv.FormatFloat(boost, 'f', -1, 64)
+}
+
+func SearchPosts(ctx context.Context, es *ESClient, index, query string, opts SearchOptions) (_ *SearchResult, err error) {
+	if err := opts.CheckWindow(); err != nil {
+		return nil, err
+	}
+	size := opts.size()
 	body := map[string]any{
 		"query": map[string]any{
 			"multi_match": map[string]any{
 				"query":  query,
-				"fields": []string{"title", "content"},
+				"fields": []string{boosted("title", opts.TitleBoost), boosted("content", opts.ContentBoost)},
 			},
 		},
+		"size": size,
+		"sort": opts.sort(),
+		// _score is not computed when sorting on another field unless asked
+		"track_scores": true,
 	}
+	if opts.SearchAfter != "" {
+		after, err := decodeSearchAfter(opts.SearchAfter)
+		if err != nil {
+			return nil, err
+		}
+		body["search_after"] = after
+	} else if opts.From > 0 {
+		body["from"] = opts.From
+	}
+	if opts.Highlight {
+		body["highlight"] = map[string]any{
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields": map[string]any{
+				"title":   map[string]any{"number_of_fragments": 0},
+				"content": map[string]any{"fragment_size": 150, "number_of_fragments": 3},
+			},
+		}
+	}
+
+	ctx, done := instrument(ctx, "search")
+	defer func() { done(err) }()
 	b, _ := json.Marshal(body)
 	res, err := es.Client.Search(
+		es.Client.Search.WithContext(ctx),
 		es.Client.Search.WithIndex(index),
 		es.Client.Search.WithBody(bytes.NewReader(b)),
 		es.Client.Search.WithTrackTotalHits(true),
 	)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer res.Body.Close()
 	if res.IsError() {
 		raw, _ := io.ReadAll(res.Body)
-		return nil, 0, fmt.Errorf("es search error: %s", string(raw))
+		return nil, fmt.Errorf("es search error: %s", string(raw))
 	}
 	var out struct {
 		Hits struct {
@@ -124,16 +223,93 @@ func SearchPosts(ctx context.Context, es *ESClient, index, query string) ([]Post
 				Value int `json:"value"`
 			} `json:"total"`
 			Hits []struct {
-				Source PostDoc `json:"_source"`
+				Score     *float64            `json:"_score"`
+				Source    PostDoc             `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+				Sort      []any               `json:"sort"`
 			} `json:"hits"`
 		} `json:"hits"`
 	}
 	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	result := &SearchResult{
+		Items:   make([]SearchHit, 0, len(out.Hits.Hits)),
+		Total:   out.Hits.Total.Value,
+		Backend: BackendElasticsearch,
 	}
-	items := make([]PostDoc, 0, len(out.Hits.Hits))
 	for _, h := range out.Hits.Hits {
-		items = append(items, h.Source)
+		result.Items = append(result.Items, SearchHit{PostDoc: h.Source, Score: h.Score, Highlight: h.Highlight})
+	}
+	if n := len(out.Hits.Hits); n == size {
+		result.Next = encodeSearchAfter(out.Hits.Hits[n-1].Sort)
+	}
+	return result, nil
+}
+
+func encodeSearchAfter(sort []any) string {
+	if len(sort) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(sort)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSearchAfter(token string) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidSearchAfter
+	}
+	var after []any
+	if err := json.Unmarshal(b, &after); err != nil || len(after) == 0 {
+		return nil, ErrInvalidSearchAfter
+	}
+	return after, nil
+}
+
+func DeletePost(ctx context.Context, es *ESClient, index string, id int) (err error) {
+	ctx, done := instrument(ctx, "delete")
+	defer func() { done(err) }()
+	res, err := es.Client.Delete(index, fmt.Sprint(id), es.Client.Delete.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// already gone from the index is fine
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		b, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("delete error: %s", string(b))
 	}
-	return items, out.Hits.Total.Value, nil
+	return nil
+}
+
+// Ping checks that the cluster answers.
+func (es *ESClient) Ping(ctx context.Context) error {
+	res, err := es.Client.Info(es.Client.Info.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("es info error: %s", res.Status())
+	}
+	return nil
+}
+
+type esBackend struct {
+	es    *ESClient
+	index string
+}
+
+// NewESBackend searches the given index or alias in Elasticsearch.
+func NewESBackend(es *ESClient, index string) Backend {
+	return &esBackend{es: es, index: index}
+}
+
+func (b *esBackend) Name() string { return BackendElasticsearch }
+
+func (b *esBackend) Healthy(ctx context.Context) error { return b.es.Ping(ctx) }
+
+func (b *esBackend) Search(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	return SearchPosts(ctx, b.es, b.index, query, opts)
 }
@@ -0,0 +1,197 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// postMapping is the mapping new posts indices are created with. Changing it
+// takes effect on the next Reindex.
+func postMapping() map[string]any {
+	return map[string]any{
+		"mappings": map[string]any{
+			"properties": map[string]any{
+				"id":         map[string]any{"type": "integer"},
+				"title":      map[string]any{"type": "text", "fields": map[string]any{"keyword": map[string]any{"type": "keyword"}}},
+				"content":    map[string]any{"type": "text"},
+				"tags":       map[string]any{"type": "keyword"},
+				"created_at": map[string]any{"type": "date"},
+			},
+		},
+	}
+}
+
+// VersionedIndex names the concrete index behind alias, e.g. posts_v2.
+func VersionedIndex(alias string, version int) string {
+	return alias + "_v" + strconv.Itoa(version)
+}
+
+// NextIndex returns the versioned index name following the highest version
+// among existing.
+func NextIndex(alias string, existing []string) string {
+	max := 0
+	for _, name := range existing {
+		if v, err := strconv.Atoi(strings.TrimPrefix(name, alias+"_v")); err == nil && v > max {
+			max = v
+		}
+	}
+	return VersionedIndex(alias, max+1)
+}
+
+func CreateIndex(ctx context.Context, es *ESClient, index string) error {
+	buf, _ := json.Marshal(postMapping())
+	res, err := es.Client.Indices.Create(index,
+		es.Client.Indices.Create.WithContext(ctx),
+		es.Client.Indices.Create.WithBody(bytes.NewReader(buf)),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		b, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("create index error: %s", string(b))
+	}
+	return nil
+}
+
+func DeleteIndex(ctx context.Context, es *ESClient, index string) error {
+	res, err := es.Client.Indices.Delete([]string{index}, es.Client.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		b, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("delete index error: %s", string(b))
+	}
+	return nil
+}
+
+// IndexExists reports whether a concrete index or alias named index exists.
+func IndexExists(ctx context.Context, es *ESClient, index string) (bool, error) {
+	res, err := es.Client.Indices.Exists([]string{index}, es.Client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// AliasIndices lists the concrete indices alias points at, sorted by name.
+// A missing alias yields an empty list.
+func AliasIndices(ctx context.Context, es *ESClient, alias string) ([]string, error) {
+	res, err := es.Client.Indices.GetAlias(
+		es.Client.Indices.GetAlias.WithContext(ctx),
+		es.Client.Indices.GetAlias.WithName(alias),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		b, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("get alias error: %s", string(b))
+	}
+	var out map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	indices := make([]string, 0, len(out))
+	for name := range out {
+		indices = append(indices, name)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// SwapAlias atomically points alias at index and away from every index in
+// old. If a concrete index carries the alias name itself it is removed in
+// the same request, since an alias cannot share a name with an index.
+func SwapAlias(ctx context.Context, es *ESClient, alias, index string, old []string) error {
+	actions := []any{
+		map[string]any{"add": map[string]any{"index": index, "alias": alias, "is_write_index": true}},
+	}
+	for _, o := range old {
+		if o == index {
+			continue
+		}
+		if o == alias {
+			actions = append(actions, map[string]any{"remove_index": map[string]any{"index": o}})
+			continue
+		}
+		actions = append(actions, map[string]any{"remove": map[string]any{"index": o, "alias": alias}})
+	}
+	buf, _ := json.Marshal(map[string]any{"actions": actions})
+	res, err := es.Client.Indices.UpdateAliases(bytes.NewReader(buf), es.Client.Indices.UpdateAliases.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		b, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("update aliases error: %s", string(b))
+	}
+	return nil
+}
+
+// BulkIndex writes docs to index with a single _bulk request. Documents
+// rejected by ES are returned by id with the reason; err is only set when
+// the request as a whole failed.
+func BulkIndex(ctx context.Context, es *ESClient, index string, docs []PostDoc) (_ map[int]string, err error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	ctx, done := instrument(ctx, "bulk")
+	defer func() { done(err) }()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range docs {
+		_ = enc.Encode(map[string]any{"index": map[string]any{"_index": index, "_id": strconv.Itoa(d.ID)}})
+		_ = enc.Encode(d)
+	}
+	res, err := es.Client.Bulk(&buf, es.Client.Bulk.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		b, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("bulk error: %s", string(b))
+	}
+	var out struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if !out.Errors {
+		return nil, nil
+	}
+	failed := make(map[int]string)
+	for _, item := range out.Items {
+		for _, r := range item {
+			if r.Status < 300 {
+				continue
+			}
+			id, _ := strconv.Atoi(r.ID)
+			failed[id] = string(r.Error)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,22 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"github.com/xuanviet96/seta-training/internal/metrics"
+	"github.com/xuanviet96/seta-training/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrument starts a span for one Elasticsearch request; the returned
+// func ends it and records the request's latency and outcome.
+func instrument(ctx context.Context, op string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "es."+op, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func(err error) {
+		metrics.ObserveSearch(op, start, err)
+		tracing.End(span, err)
+	}
+}
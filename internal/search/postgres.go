@@ -0,0 +1,177 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// pgConfig is the text search configuration the search_vector column is
+// built with; queries must use the same one.
+const pgConfig = "english"
+
+type pgBackend struct {
+	db *gorm.DB
+}
+
+// NewPostgresBackend searches posts.search_vector with websearch_to_tsquery.
+// It is slower and less tunable than Elasticsearch but needs nothing beyond
+// the primary database.
+func NewPostgresBackend(db *gorm.DB) Backend {
+	return &pgBackend{db: db}
+}
+
+func (b *pgBackend) Name() string { return BackendPostgres }
+
+func (b *pgBackend) Healthy(ctx context.Context) error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+type pgHit struct {
+	ID        int
+	Title     string
+	Content   string
+	Tags      pq.StringArray
+	CreatedAt time.Time
+	Score     float64
+	TitleHL   string `gorm:"column:title_hl"`
+	ContentHL string `gorm:"column:content_hl"`
+}
+
+func (b *pgBackend) Search(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	if err := opts.CheckWindow(); err != nil {
+		return nil, err
+	}
+	size := opts.size()
+
+	// setweight puts title in A and content in B; ts_rank takes weights in
+	// {D,C,B,A} order and wants them in [0,1].
+	tb, cb := opts.TitleBoost, opts.ContentBoost
+	if tb <= 0 {
+		tb = 1
+	}
+	if cb <= 0 {
+		cb = 1
+	}
+	max := tb
+	if cb > max {
+		max = cb
+	}
+	rank := fmt.Sprintf("ts_rank('{0.1,0.2,%g,%g}'::real[], p.search_vector, q.tsq)", cb/max, tb/max)
+
+	key, order, dir, cmp := rank, "score", "DESC", "<"
+	if opts.Sort == SortCreatedAt {
+		key, order = "p.created_at", "created_at"
+	}
+	if opts.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+
+	where := "p.deleted_at IS NULL AND p.search_vector @@ q.tsq"
+	args := []any{query}
+	offset := 0
+	if opts.SearchAfter != "" {
+		after, err := decodeSearchAfter(opts.SearchAfter)
+		if err != nil || len(after) != 2 {
+			return nil, ErrInvalidSearchAfter
+		}
+		id, ok := after[1].(float64)
+		if !ok {
+			return nil, ErrInvalidSearchAfter
+		}
+		var k any
+		switch v := after[0].(type) {
+		case float64:
+			if opts.Sort == SortCreatedAt {
+				return nil, ErrInvalidSearchAfter
+			}
+			k = v
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil || opts.Sort != SortCreatedAt {
+				return nil, ErrInvalidSearchAfter
+			}
+			k = t
+		default:
+			return nil, ErrInvalidSearchAfter
+		}
+		cast := "::real"
+		if opts.Sort == SortCreatedAt {
+			cast = ""
+		}
+		// id always breaks ties descending, as in the ES sort
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ?%[3]s OR (%[1]s = ?%[3]s AND p.id < ?))", key, cmp, cast)
+		args = append(args, k, k, int(id))
+	} else if opts.From > 0 {
+		offset = opts.From
+	}
+
+	base := fmt.Sprintf("WITH q AS (SELECT websearch_to_tsquery('%s', ?) AS tsq) ", pgConfig)
+
+	var total int64
+	if err := b.db.WithContext(ctx).
+		Raw(base+"SELECT COUNT(*) FROM posts p, q WHERE p.deleted_at IS NULL AND p.search_vector @@ q.tsq", query).
+		Scan(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// Rank and page first, then build headlines only for the rows returned
+	inner := fmt.Sprintf(
+		"SELECT p.id, p.title, p.content, p.tags, p.created_at, %s AS score, q.tsq FROM posts p, q WHERE %s ORDER BY %s %s, p.id DESC LIMIT ? OFFSET ?",
+		rank, where, order, dir)
+	args = append(args, size, offset)
+	cols := "id, title, content, tags, created_at, score"
+	if opts.Highlight {
+		cols += fmt.Sprintf(
+			", ts_headline('%[1]s', title, tsq, 'HighlightAll=true, StartSel=<em>, StopSel=</em>') AS title_hl"+
+				", ts_headline('%[1]s', content, tsq, 'StartSel=<em>, StopSel=</em>, MaxFragments=3, MaxWords=30, MinWords=10') AS content_hl",
+			pgConfig)
+	}
+	sql := fmt.Sprintf("%sSELECT %s FROM (%s) r ORDER BY %s %s, id DESC", base, cols, inner, order, dir)
+
+	var rows []pgHit
+	if err := b.db.WithContext(ctx).Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		Items:   make([]SearchHit, 0, len(rows)),
+		Total:   int(total),
+		Backend: BackendPostgres,
+	}
+	for _, r := range rows {
+		score := r.Score
+		hit := SearchHit{
+			PostDoc: PostDoc{ID: r.ID, Title: r.Title, Content: r.Content, Tags: []string(r.Tags), CreatedAt: r.CreatedAt},
+			Score:   &score,
+		}
+		// ts_headline returns the text unmarked when nothing matched in it
+		if opts.Highlight && strings.Contains(r.TitleHL, "<em>") {
+			hit.Highlight = map[string][]string{"title": {r.TitleHL}}
+		}
+		if opts.Highlight && strings.Contains(r.ContentHL, "<em>") {
+			if hit.Highlight == nil {
+				hit.Highlight = map[string][]string{}
+			}
+			hit.Highlight["content"] = strings.Split(r.ContentHL, " ... ")
+		}
+		result.Items = append(result.Items, hit)
+	}
+	if n := len(rows); n == size {
+		last := rows[n-1]
+		var k any = last.Score
+		if opts.Sort == SortCreatedAt {
+			k = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		result.Next = encodeSearchAfter([]any{k, last.ID})
+	}
+	return result, nil
+}
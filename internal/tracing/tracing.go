@@ -0,0 +1,146 @@
+// Package tracing sets up OpenTelemetry and instruments the clients the
+// service talks to. Spans use W3C traceparent propagation.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const instrumentation = "github.com/xuanviet96/seta-training"
+
+// Tracer returns the service tracer from the global provider, a no-op
+// until Setup or Install runs.
+func Tracer() trace.Tracer { return otel.Tracer(instrumentation) }
+
+// Setup installs the exporter selected by cfg.Tracing.Exporter and returns
+// a function that flushes pending spans on shutdown.
+func Setup(cfg config.Config) (func(context.Context) error, error) {
+	var (
+		exp      sdktrace.SpanExporter
+		closeOut func() error
+	)
+	switch cfg.Tracing.Exporter {
+	case "", ExporterNone:
+		Install(nil)
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		w, c, err := output(cfg.Tracing.Output)
+		if err != nil {
+			return nil, err
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			_ = c()
+			return nil, err
+		}
+		exp, closeOut = e, c
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint)}
+		if cfg.Tracing.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		// connects lazily; spans are retried and dropped, never block requests
+		e, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		exp = e
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Tracing.Exporter)
+	}
+	tp := NewProvider(exp, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+	Install(tp)
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closeOut != nil {
+			err = errors.Join(err, closeOut())
+		}
+		return err
+	}, nil
+}
+
+// output opens the stdout exporter's destination.
+func output(name string) (io.Writer, func() error, error) {
+	switch name {
+	case "stdout":
+		return os.Stdout, func() error { return nil }, nil
+	case "stderr":
+		return os.Stderr, func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open trace output: %w", err)
+	}
+	return f, f.Close, nil
+}
+
+// NewProvider builds a provider that batches spans to exp. Tests pass an
+// in-memory exporter from sdk/trace/tracetest with a sample ratio of 1.
+func NewProvider(exp sdktrace.SpanExporter, service string, ratio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+}
+
+// Install makes tp the global provider and enables traceparent and
+// baggage propagation. A nil tp keeps the no-op provider.
+func Install(tp trace.TracerProvider) {
+	if tp != nil {
+		otel.SetTracerProvider(tp)
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// End records err on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject serialises the span context in ctx as a traceparent header value,
+// for work that continues outside the request such as outbox events.
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// LinkFrom returns a link to the span serialised by Inject, if any.
+func LinkFrom(traceparent string) []trace.Link {
+	if traceparent == "" {
+		return nil
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceparent})
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []trace.Link{{SpanContext: sc}}
+}
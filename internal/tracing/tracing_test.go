@@ -0,0 +1,116 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xuanviet96/seta-training/internal/config"
+	"github.com/xuanviet96/seta-training/internal/http/middleware"
+	"github.com/xuanviet96/seta-training/internal/search"
+	"github.com/xuanviet96/seta-training/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestRequestSpanChain checks that the Gorm, Redis and Elasticsearch
+// spans of a request are children of its HTTP server span.
+func TestRequestSpanChain(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tracing.Install(tp)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	// Gorm in dry-run mode builds statements without a database
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracing.InstrumentGorm(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing listens here; the failed command is still traced
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	rdb.AddHook(tracing.RedisHook{})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	esSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version":{"number":"8.11.0"}}`))
+	}))
+	t.Cleanup(esSrv.Close)
+	cfg := config.Defaults()
+	cfg.ES.Addr = esSrv.URL
+	es, err := search.New(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Tracing())
+	r.GET("/posts/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var posts []struct{ ID int }
+		db.WithContext(ctx).Table("posts").Where("id = ?", 1).Find(&posts)
+		_ = rdb.Get(ctx, "post:1").Err()
+		_ = search.DeletePost(ctx, es, "posts", 1)
+		c.Status(http.StatusOK)
+	})
+	exp.Reset() // drop the spans of the setup above
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/posts/1", nil))
+
+	spans := exp.GetSpans()
+	var root *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			root = &spans[i]
+		}
+	}
+	if root == nil {
+		t.Fatalf("no server span among %d spans", len(spans))
+	}
+	if root.Name != "GET /posts/:id" {
+		t.Errorf("server span name = %q", root.Name)
+	}
+
+	tests := []struct {
+		name string
+	}{
+		{"db.query posts"},
+		{"redis.get"},
+		{"es.delete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, s := range spans {
+				if s.Name != tt.name {
+					continue
+				}
+				if s.Parent.SpanID() != root.SpanContext.SpanID() {
+					t.Errorf("parent = %s, want the server span %s", s.Parent.SpanID(), root.SpanContext.SpanID())
+				}
+				if s.SpanContext.TraceID() != root.SpanContext.TraceID() {
+					t.Errorf("span is in another trace")
+				}
+				return
+			}
+			var names []string
+			for _, s := range spans {
+				names = append(names, s.Name)
+			}
+			t.Errorf("no %q span; got %v", tt.name, names)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_title_prefix;
+DROP INDEX IF EXISTS idx_posts_created_at_id;
@@ -0,0 +1,5 @@
+-- Keyset pagination over (created_at, id) for live posts
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id ON posts (created_at DESC, id DESC) WHERE deleted_at IS NULL;
+
+-- Title prefix filter
+CREATE INDEX IF NOT EXISTS idx_posts_title_prefix ON posts (title text_pattern_ops);
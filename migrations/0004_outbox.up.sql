@@ -0,0 +1,15 @@
+-- Transactional outbox for search index operations
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id SERIAL PRIMARY KEY,
+  op VARCHAR NOT NULL,
+  post_id INT NOT NULL,
+  status VARCHAR NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  available_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMP NULL
+);
+
+-- Dispatcher polls pending events in delivery order
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (available_at, id) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_search_vector_gin;
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
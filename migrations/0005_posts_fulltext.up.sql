@@ -0,0 +1,9 @@
+-- Postgres full-text search, used when Elasticsearch is unavailable.
+-- Title is weighted A and content B so ts_rank can boost title matches.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+  GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(content, '')), 'B')
+  ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector_gin ON posts USING GIN (search_vector);
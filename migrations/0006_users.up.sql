@@ -0,0 +1,13 @@
+-- Users and post authorship
+CREATE TABLE IF NOT EXISTS users (
+  id SERIAL PRIMARY KEY,
+  email VARCHAR NOT NULL UNIQUE,
+  password_hash VARCHAR NOT NULL,
+  role VARCHAR NOT NULL DEFAULT 'author',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Posts created before authentication existed have no author
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS author_id INT NULL REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_author_id ON posts (author_id);
@@ -0,0 +1,20 @@
+-- Post revision history, one row per create/update/rollback
+CREATE TABLE IF NOT EXISTS post_revisions (
+  id SERIAL PRIMARY KEY,
+  post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  revision INT NOT NULL,
+  title VARCHAR NOT NULL,
+  content TEXT NOT NULL,
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  action VARCHAR NOT NULL,
+  rollback_of INT NULL,
+  editor_id INT NULL REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (post_id, revision)
+);
+
+-- Existing posts start their history with their current content
+INSERT INTO post_revisions (post_id, revision, title, content, tags, action, editor_id, created_at)
+SELECT p.id, 1, p.title, p.content, p.tags, 'create', p.author_id, p.created_at
+FROM posts p
+WHERE NOT EXISTS (SELECT 1 FROM post_revisions r WHERE r.post_id = p.id);
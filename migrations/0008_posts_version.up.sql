@@ -0,0 +1,2 @@
+-- Optimistic concurrency: every successful update bumps the version
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,17 @@
+-- Responses to POST requests carrying an Idempotency-Key, replayed on retry
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL DEFAULT 0,
+  key VARCHAR(255) NOT NULL,
+  fingerprint VARCHAR(64) NOT NULL,
+  status VARCHAR NOT NULL DEFAULT 'processing',
+  response_code INT NOT NULL DEFAULT 0,
+  response_headers JSONB NOT NULL DEFAULT '{}',
+  response_body BYTEA,
+  locked_until TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,3 @@
+-- W3C traceparent of the request that wrote the event, so delivery spans
+-- can link back to it
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS trace_parent TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Retention sweep deletes delivered events oldest first
+CREATE INDEX IF NOT EXISTS idx_outbox_events_done ON outbox_events (processed_at) WHERE status = 'done';
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema migrations. Each version has a
+// NNNN_name.up.sql and a NNNN_name.down.sql file.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -1,16 +1,22 @@
 package response
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 func OK(c *gin.Context, data any) {
 	c.JSON(200, data)
 }
 
+// Error writes a minimal RFC 7807 problem body.
 func Error(c *gin.Context, code int, message string) {
+	c.Header("Content-Type", "application/problem+json")
 	c.JSON(code, gin.H{
-		"error": gin.H{
-			"code":    code,
-			"message": message,
-		},
+		"type":   "about:blank",
+		"title":  http.StatusText(code),
+		"status": code,
+		"detail": message,
 	})
 }
@@ -0,0 +1,210 @@
+// Package textdiff produces line-based unified diffs.
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits on what Unified will diff. Memory grows with the square of the
+// edit distance, so both are capped.
+const (
+	MaxLines = 20000
+	MaxEdits = 2000
+)
+
+// ErrTooLarge is returned when an input has more than MaxLines lines or
+// the inputs differ by more than MaxEdits lines.
+var ErrTooLarge = errors.New("texts too large or too different to diff")
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+	// lines of a and b that precede this op
+	aPos, bPos int
+}
+
+// Unified returns a unified diff of a and b with ctx lines of context, or
+// an empty string when they are equal.
+func Unified(fromName, toName, a, b string, ctx int) (string, error) {
+	al, bl := splitLines(a), splitLines(b)
+	if len(al) > MaxLines || len(bl) > MaxLines {
+		return "", ErrTooLarge
+	}
+	ops, err := diffLines(al, bl, MaxEdits)
+	if err != nil {
+		return "", err
+	}
+	hunks := group(ops, ctx)
+	if len(hunks) == 0 {
+		return "", nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		writeHunk(&sb, h)
+	}
+	return sb.String(), nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines is Myers' O(ND) shortest edit script. Each step keeps only the
+// 2d+1 diagonals it reached, so the trace takes O(D²) memory rather than
+// O((N+M)·D); it gives up with ErrTooLarge past maxEdits.
+func diffLines(a, b []string, maxEdits int) ([]op, error) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	off := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d][k+d] is v[off+k] as it stood before step d
+	var trace [][]int32
+	found := false
+
+outer:
+	for d := 0; d <= max; d++ {
+		w := make([]int32, 2*d+1)
+		for i := range w {
+			w[i] = int32(v[off-d+i])
+		}
+		trace = append(trace, w)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break outer
+			}
+		}
+	}
+	if !found {
+		return nil, ErrTooLarge
+	}
+
+	// walk the trace backwards from (n, m) to recover the edits
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		w := trace[d]
+		at := func(k int) int { return int(w[k+d]) }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, op{kind: opEqual, line: a[x-1], aPos: x - 1, bPos: y - 1})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			ops = append(ops, op{kind: opInsert, line: b[y-1], aPos: x, bPos: y - 1})
+			y--
+		} else {
+			ops = append(ops, op{kind: opDelete, line: a[x-1], aPos: x - 1, bPos: y})
+			x--
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, nil
+}
+
+// group splits ops into hunks of changes with up to ctx equal lines around
+// them, merging hunks whose context would overlap.
+func group(ops []op, ctx int) [][]op {
+	var hunks [][]op
+	start, end := -1, -1
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		lo, hi := i-ctx, i+ctx+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, ops[start:end])
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		hunks = append(hunks, ops[start:end])
+	}
+	return hunks
+}
+
+func writeHunk(sb *strings.Builder, h []op) {
+	aLen, bLen := 0, 0
+	for _, o := range h {
+		if o.kind != opInsert {
+			aLen++
+		}
+		if o.kind != opDelete {
+			bLen++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(h[0].aPos, aLen), hunkRange(h[0].bPos, bLen))
+	for _, o := range h {
+		sb.WriteByte(byte(o.kind))
+		sb.WriteString(o.line)
+		sb.WriteByte('\n')
+	}
+}
+
+// hunkRange formats a range that follows pos lines. An empty range is
+// reported at the line before it, as diff -u does.
+func hunkRange(pos, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", pos)
+	case 1:
+		return fmt.Sprint(pos + 1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, n)
+}
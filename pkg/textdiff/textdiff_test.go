@@ -0,0 +1,137 @@
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		ctx  int
+		want string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", ctx: 3, want: ""},
+		{name: "both empty", a: "", b: "", ctx: 3, want: ""},
+		{
+			name: "from empty",
+			a:    "", b: "x\ny\n", ctx: 3,
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "to empty",
+			a:    "x\n", b: "", ctx: 3,
+			want: "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n",
+		},
+		{
+			name: "replace middle line",
+			a:    "1\n2\n3\n", b: "1\nX\n3\n", ctx: 1,
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n",
+		},
+		{
+			name: "no context",
+			a:    "1\n2\n3\n", b: "1\n3\n", ctx: 0,
+			want: "--- a\n+++ b\n@@ -2 +1,0 @@\n-2\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n", b: "X\n2\n3\n4\n5\n6\nY\n", ctx: 1,
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+X\n 2\n@@ -6,2 +6,2 @@\n 6\n-7\n+Y\n",
+		},
+		{
+			name: "overlapping context merges",
+			a:    "1\n2\n3\n4\n", b: "X\n2\n3\nY\n", ctx: 1,
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+X\n 2\n 3\n-4\n+Y\n",
+		},
+		{
+			name: "missing trailing newline is ignored",
+			a:    "a\nb", b: "a\nb\n", ctx: 3, want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unified("a", "b", tt.a, tt.b, tt.ctx)
+			if err != nil {
+				t.Fatalf("Unified: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffLinesRoundTrip checks that applying the script to a gives b and
+// that it is no longer than the number of differing lines requires.
+func TestDiffLinesRoundTrip(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"abcabba", "cbabac", 5},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abc", "abc", 0},
+		{"abcd", "acbd", 2},
+		{"xaxbxc", "abc", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"->"+tt.b, func(t *testing.T) {
+			a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+			ops, err := diffLines(a, b, MaxEdits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotA, gotB []string
+			edits := 0
+			for _, o := range ops {
+				if o.kind != opInsert {
+					gotA = append(gotA, o.line)
+				}
+				if o.kind != opDelete {
+					gotB = append(gotB, o.line)
+				}
+				if o.kind != opEqual {
+					edits++
+				}
+			}
+			if strings.Join(gotA, "") != tt.a || strings.Join(gotB, "") != tt.b {
+				t.Errorf("script rebuilds %q -> %q", strings.Join(gotA, ""), strings.Join(gotB, ""))
+			}
+			if edits != tt.edits {
+				t.Errorf("edits = %d, want %d", edits, tt.edits)
+			}
+		})
+	}
+}
+
+func TestUnifiedLimits(t *testing.T) {
+	lines := func(prefix string, n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&sb, "%s%d\n", prefix, i)
+		}
+		return sb.String()
+	}
+	tests := []struct {
+		name    string
+		a, b    string
+		wantErr bool
+	}{
+		{"too many lines", lines("a", MaxLines+1), "", true},
+		{"too many edits", lines("a", MaxEdits), lines("b", MaxEdits), true},
+		{"long but similar", lines("a", MaxLines), lines("a", MaxLines-10), false},
+		{"at edit limit", lines("a", MaxEdits/2), lines("b", MaxEdits/2), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unified("a", "b", tt.a, tt.b, 3)
+			if got := errors.Is(err, ErrTooLarge); got != tt.wantErr {
+				t.Errorf("ErrTooLarge = %v, want %v (err %v)", got, tt.wantErr, err)
+			}
+		})
+	}
+}